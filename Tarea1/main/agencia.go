@@ -0,0 +1,22 @@
+package main
+
+import "net/http"
+
+// Clave de la agencia. El servidor la exige cuando atiende a varias
+// agencias y con ella elige las credenciales, la moneda y la politica
+var claveAgencia string
+
+// Agrega la cabecera X-API-Key a las solicitudes al servidor
+type transporteAgencia struct {
+	base http.RoundTripper
+}
+
+func (t transporteAgencia) RoundTrip(req *http.Request) (*http.Response, error) {
+	if claveAgencia == "" {
+		return t.base.RoundTrip(req)
+	}
+	// Un RoundTripper no debe modificar la solicitud original
+	copia := req.Clone(req.Context())
+	copia.Header.Set("X-API-Key", claveAgencia)
+	return t.base.RoundTrip(copia)
+}
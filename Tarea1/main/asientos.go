@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+type SeatMap struct {
+	FlightOfferId string    `json:"flightOfferId"`
+	SegmentId     string    `json:"segmentId"`
+	CarrierCode   string    `json:"carrierCode"`
+	Number        string    `json:"number"`
+	Departure     Departure `json:"departure"`
+	Arrival       Arrival   `json:"arrival"`
+	Decks         []struct {
+		Seats []Seat `json:"seats"`
+	} `json:"decks"`
+	Simulated bool `json:"simulated"`
+}
+
+type Seat struct {
+	Number          string `json:"number"`
+	TravelerPricing []struct {
+		TravelerId             string `json:"travelerId"`
+		SeatAvailabilityStatus string `json:"seatAvailabilityStatus"`
+		Price                  *struct {
+			Currency string `json:"currency"`
+			Total    string `json:"total"`
+		} `json:"price"`
+	} `json:"travelerPricing"`
+	Coordinates struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+	} `json:"coordinates"`
+}
+
+// Estado del asiento para un pasajero y su precio, si tiene
+func (s Seat) disponibilidad(travelerId string) (bool, string) {
+	for _, pricing := range s.TravelerPricing {
+		if pricing.TravelerId != travelerId && travelerId != "" {
+			continue
+		}
+		precio := ""
+		if pricing.Price != nil && strings.Trim(pricing.Price.Total, "0.") != "" {
+			precio = pricing.Price.Total + " " + pricing.Price.Currency
+		}
+		return pricing.SeatAvailabilityStatus == "AVAILABLE", precio
+	}
+	return false, ""
+}
+
+// Permite a cada pasajero elegir un asiento en cada segmento. Los asientos
+// elegidos se agregan a la oferta y, si alguno tiene costo, se vuelve a
+// cotizar para obtener el total actualizado; si esa cotización falla se
+// reserva sin asientos. Los mapas simulados solo se muestran
+func seleccionarAsientos(ctx context.Context, precios PricingResponse) PricingResponse {
+	var respuesta string
+	fmt.Print("¿Desea elegir asientos? (s/n): ")
+	fmt.Scanln(&respuesta)
+	if !strings.EqualFold(respuesta, "s") {
+		return precios
+	}
+
+	mapas, err := obtenerMapasAsientos(ctx, precios.FlightOffers)
+	if err != nil {
+		fmt.Println("No se pudieron obtener los mapas de asientos:", err)
+		return precios
+	}
+	if mapasSimulados(mapas) {
+		// Los asientos no existen en el proveedor, no se pueden reservar
+		fmt.Println("Los mapas de asientos son simulados, solo se muestran:")
+		for _, mapa := range mapas {
+			fmt.Printf("Vuelo %s%s %s-%s:\n", mapa.CarrierCode, mapa.Number, mapa.Departure.IataCode, mapa.Arrival.IataCode)
+			dibujarMapaAsientos(mapa, nil)
+		}
+		return precios
+	}
+	originales := copiarOfertas(precios.FlightOffers)
+	if originales == nil {
+		return precios
+	}
+
+	conCosto := false
+	for _, mapa := range mapas {
+		asientos := make(map[string]Seat)
+		for _, cubierta := range mapa.Decks {
+			for _, asiento := range cubierta.Seats {
+				asientos[asiento.Number] = asiento
+			}
+		}
+
+		for i := range precios.FlightOffers {
+			offer := &precios.FlightOffers[i]
+			if offer.Id != mapa.FlightOfferId {
+				continue
+			}
+
+			elegidos := make(map[string]bool)
+			fmt.Printf("Vuelo %s%s %s-%s:\n", mapa.CarrierCode, mapa.Number, mapa.Departure.IataCode, mapa.Arrival.IataCode)
+			dibujarMapaAsientos(mapa, elegidos)
+
+			for j := range offer.TravelerPricings {
+				pricing := &offer.TravelerPricings[j]
+				// Los infantes en brazos no tienen asiento
+				if pricing.TravelerType == "HELD_INFANT" {
+					continue
+				}
+
+				for {
+					var numero string
+					fmt.Printf("Pasajero %s (%s), asiento (enter para omitir): ", pricing.TravelerId, describirPasajero(*pricing))
+					fmt.Scanln(&numero)
+					numero = strings.ToUpper(strings.TrimSpace(numero))
+					if numero == "" {
+						break
+					}
+
+					asiento, ok := asientos[numero]
+					disponible, precio := asiento.disponibilidad(pricing.TravelerId)
+					if !ok || !disponible || elegidos[numero] {
+						fmt.Println("El asiento no existe o no está disponible, elija otro.")
+						continue
+					}
+					if precio != "" {
+						fmt.Println("El asiento tiene un costo de", precio)
+						conCosto = true
+					}
+
+					elegidos[numero] = true
+					asignarAsiento(pricing, mapa.SegmentId, numero)
+					// Se vuelve a dibujar la cabina con el asiento elegido
+					dibujarMapaAsientos(mapa, elegidos)
+					break
+				}
+			}
+		}
+	}
+
+	if !conCosto {
+		return precios
+	}
+
+	nuevos, err := cotizar(ctx, precios.FlightOffers)
+	if err != nil || len(nuevos.FlightOffers) == 0 {
+		// Sin el nuevo total no se puede reservar con los asientos
+		fmt.Println("Error al cotizar los asientos, se continúa sin ellos:", err)
+		precios.FlightOffers = originales
+		return precios
+	}
+	for _, offer := range nuevos.FlightOffers {
+		fmt.Println("El precio total con asientos es de: ", offer.Price.GrandTotal)
+	}
+	return nuevos
+}
+
+func mapasSimulados(mapas []SeatMap) bool {
+	for _, mapa := range mapas {
+		if mapa.Simulated {
+			return true
+		}
+	}
+	return false
+}
+
+func asignarAsiento(pricing *TravelerPricing, segmentId, numero string) {
+	for k := range pricing.FareDetailsBySegment {
+		detalle := &pricing.FareDetailsBySegment[k]
+		if detalle.SegmentId != segmentId {
+			continue
+		}
+		if detalle.AdditionalServices == nil {
+			detalle.AdditionalServices = &SegmentAdditionalServices{}
+		}
+		detalle.AdditionalServices.ChargeableSeatNumber = numero
+	}
+}
+
+func obtenerMapasAsientos(ctx context.Context, flightOffers []FlightOffer) ([]SeatMap, error) {
+	var solicitud FlightOffersPricing
+	solicitud.Data.Type = "flight-offers-pricing"
+	solicitud.Data.FlightOffers = flightOffers
+
+	resultJSON, err := json.Marshal(solicitud)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", urlServidor+"/seatmaps", bytes.NewBuffer(resultJSON))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := clienteHTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("estado %d: %s", resp.StatusCode, respBody)
+	}
+
+	var mapas []SeatMap
+	if err := json.Unmarshal(respBody, &mapas); err != nil {
+		return nil, err
+	}
+	return mapas, nil
+}
+
+// Dibuja la cabina con una fila por línea: "." libre, "$" libre con costo,
+// "X" ocupado y "*" elegido. Se deja un pasillo donde las columnas no son
+// contiguas
+func dibujarMapaAsientos(mapa SeatMap, elegidos map[string]bool) {
+	filas := make(map[int]map[int]Seat)
+	columnas := make(map[int]string)
+	for _, cubierta := range mapa.Decks {
+		for _, asiento := range cubierta.Seats {
+			if filas[asiento.Coordinates.X] == nil {
+				filas[asiento.Coordinates.X] = make(map[int]Seat)
+			}
+			filas[asiento.Coordinates.X][asiento.Coordinates.Y] = asiento
+			columnas[asiento.Coordinates.Y] = strings.TrimLeft(asiento.Number, "0123456789")
+		}
+	}
+
+	var ordenColumnas, ordenFilas []int
+	for y := range columnas {
+		ordenColumnas = append(ordenColumnas, y)
+	}
+	for x := range filas {
+		ordenFilas = append(ordenFilas, x)
+	}
+	sort.Ints(ordenColumnas)
+	sort.Ints(ordenFilas)
+
+	linea := func(celda func(y int) string) string {
+		var texto strings.Builder
+		for i, y := range ordenColumnas {
+			if i > 0 && y-ordenColumnas[i-1] > 1 {
+				texto.WriteString("  ")
+			}
+			texto.WriteString(" " + celda(y))
+		}
+		return texto.String()
+	}
+
+	fmt.Println("    " + linea(func(y int) string { return columnas[y] }))
+	for _, x := range ordenFilas {
+		fila := filas[x]
+		numeroFila := ""
+		for _, asiento := range fila {
+			numeroFila = strings.TrimRight(asiento.Number, "ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+			break
+		}
+		fmt.Printf("%4s%s\n", numeroFila, linea(func(y int) string {
+			asiento, ok := fila[y]
+			if !ok {
+				return " "
+			}
+			if elegidos[asiento.Number] {
+				return "*"
+			}
+			disponible, precio := asiento.disponibilidad("")
+			switch {
+			case !disponible:
+				return "X"
+			case precio != "":
+				return "$"
+			}
+			return "."
+		}))
+	}
+	fmt.Println("  . libre   $ con costo   X ocupado")
+}
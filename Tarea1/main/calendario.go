@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// Descarga el calendario .ics de una reserva y lo guarda en un archivo
+func DescargarCalendario(ctx context.Context) error {
+	var id, archivo string
+
+	fmt.Print("Ingrese el ID de la Reserva: ")
+	fmt.Scanln(&id)
+
+	fmt.Print("Nombre del archivo (enter para reserva.ics): ")
+	fmt.Scanln(&archivo)
+	if archivo == "" {
+		archivo = "reserva.ics"
+	}
+
+	apiUrl := fmt.Sprintf(urlServidor+"/booking/%s/calendar.ics", url.PathEscape(id))
+
+	resp, err := obtenerURL(ctx, apiUrl)
+	if err != nil {
+		fmt.Println("Error al hacer la solicitud HTTP:", err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		fmt.Println("No se pudo obtener el calendario:", string(respBody))
+		return fmt.Errorf("estado %d", resp.StatusCode)
+	}
+
+	if err := os.WriteFile(archivo, respBody, 0644); err != nil {
+		fmt.Println("Error al guardar el archivo:", err)
+		return err
+	}
+
+	fmt.Println("Calendario guardado en", archivo)
+	return nil
+}
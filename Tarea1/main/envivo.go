@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Abre una busqueda en vivo del servidor y llama a recibir con cada evento
+// (nombre y datos JSON) a medida que llega. Si recibir devuelve false se
+// deja de leer
+func escucharEventos(ctx context.Context, url string, recibir func(evento string, datos []byte) bool) error {
+	resp, err := obtenerURL(ctx, url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		cuerpo, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s", strings.TrimSpace(string(cuerpo)))
+	}
+
+	lector := bufio.NewReader(resp.Body)
+	var evento string
+	var datos []string
+	for {
+		linea, err := lector.ReadString('\n')
+		linea = strings.TrimRight(linea, "\r\n")
+
+		switch {
+		case strings.HasPrefix(linea, "event:"):
+			evento = strings.TrimSpace(strings.TrimPrefix(linea, "event:"))
+		case strings.HasPrefix(linea, "data:"):
+			datos = append(datos, strings.TrimPrefix(strings.TrimPrefix(linea, "data:"), " "))
+		case linea == "" && len(datos) > 0:
+			// Una linea vacia cierra el evento
+			if !recibir(evento, []byte(strings.Join(datos, "\n"))) {
+				return nil
+			}
+			evento, datos = "", nil
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Resultado final de una busqueda: las ofertas combinadas de todos los
+// proveedores y las advertencias de los que no respondieron
+type ResultadoBusqueda struct {
+	Data     json.RawMessage `json:"data"`
+	Warnings []string        `json:"warnings"`
+}
+
+// Ofertas de un proveedor enviadas apenas responde
+type OfertasProveedor struct {
+	Provider string        `json:"provider"`
+	Data     []FlightOffer `json:"data"`
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// Muestra el equipaje incluido por segmento y las opciones adicionales
+// que entrega el pricing
+func mostrarEquipaje(precios PricingResponse) {
+	table := tablewriter.NewWriter(os.Stdout)
+	fmt.Println("Equipaje incluido:")
+	table.SetHeader([]string{"SEGMENTO", "RUTA", "CABINA", "PASAJERO", "MALETAS INCLUIDAS"})
+
+	for _, offer := range precios.FlightOffers {
+		for _, pricing := range offer.TravelerPricings {
+			for _, detalle := range pricing.FareDetailsBySegment {
+				incluidas := strconv.Itoa(detalle.IncludedCheckedBags.Quantity)
+				if detalle.IncludedCheckedBags.Weight > 0 {
+					incluidas = fmt.Sprintf("%d %s", detalle.IncludedCheckedBags.Weight, detalle.IncludedCheckedBags.WeightUnit)
+				}
+				table.Append([]string{
+					detalle.SegmentId,
+					rutaSegmento(offer, detalle.SegmentId),
+					detalle.Cabin,
+					pricing.TravelerId + " (" + pricing.TravelerType + ")",
+					incluidas,
+				})
+			}
+		}
+	}
+	table.Render()
+
+	if len(precios.Included.Bags) > 0 {
+		table := tablewriter.NewWriter(os.Stdout)
+		fmt.Println("Equipaje adicional disponible:")
+		table.SetHeader([]string{"OPCIÓN", "CANTIDAD", "PESO", "PRECIO", "SEGMENTOS"})
+		for _, clave := range clavesOrdenadas(precios.Included.Bags) {
+			bolsa := precios.Included.Bags[clave]
+			peso := ""
+			if bolsa.Weight > 0 {
+				peso = fmt.Sprintf("%d %s", bolsa.Weight, bolsa.WeightUnit)
+			}
+			table.Append([]string{
+				clave,
+				strconv.Itoa(bolsa.Quantity),
+				peso,
+				bolsa.Price.Amount + " " + bolsa.Price.CurrencyCode,
+				fmt.Sprint(bolsa.SegmentIds),
+			})
+		}
+		table.Render()
+	}
+
+	if len(precios.Included.OtherServices) > 0 {
+		table := tablewriter.NewWriter(os.Stdout)
+		fmt.Println("Servicios adicionales:")
+		table.SetHeader([]string{"SERVICIO", "PRECIO", "SEGMENTOS"})
+		for _, clave := range clavesOrdenadas(precios.Included.OtherServices) {
+			servicio := precios.Included.OtherServices[clave]
+			table.Append([]string{
+				servicio.Name,
+				servicio.Price.Amount + " " + servicio.Price.CurrencyCode,
+				fmt.Sprint(servicio.SegmentIds),
+			})
+		}
+		table.Render()
+	}
+}
+
+// Permite a cada pasajero agregar maletas adicionales. Si se agrega alguna,
+// la oferta se vuelve a cotizar para obtener el total actualizado; si esa
+// cotización falla se reserva sin las maletas
+func seleccionarEquipaje(ctx context.Context, precios PricingResponse) PricingResponse {
+	if len(precios.Included.Bags) == 0 {
+		return precios
+	}
+	originales := copiarOfertas(precios.FlightOffers)
+	if originales == nil {
+		return precios
+	}
+
+	seleccion := false
+	for i := range precios.FlightOffers {
+		offer := &precios.FlightOffers[i]
+		for j := range offer.TravelerPricings {
+			pricing := &offer.TravelerPricings[j]
+
+			var opcion string
+			fmt.Printf("Pasajero %s, ingrese una opción de equipaje adicional (0 para ninguna): ", pricing.TravelerId)
+			fmt.Scanln(&opcion)
+			if opcion == "" || opcion == "0" {
+				continue
+			}
+
+			bolsa, ok := precios.Included.Bags[opcion]
+			if !ok {
+				fmt.Println("Opción de equipaje no válida, se omite.")
+				continue
+			}
+			if !bolsa.BookableByAllTravelers && !contiene(bolsa.TravelerIds, pricing.TravelerId) {
+				fmt.Println("La opción no está disponible para este pasajero, se omite.")
+				continue
+			}
+
+			for k := range pricing.FareDetailsBySegment {
+				detalle := &pricing.FareDetailsBySegment[k]
+				if !contiene(bolsa.SegmentIds, detalle.SegmentId) {
+					continue
+				}
+				detalle.AdditionalServices = &SegmentAdditionalServices{
+					ChargeableCheckedBags: &ChargeableCheckedBags{
+						Quantity:   bolsa.Quantity,
+						Weight:     bolsa.Weight,
+						WeightUnit: bolsa.WeightUnit,
+					},
+				}
+				seleccion = true
+			}
+		}
+	}
+
+	if !seleccion {
+		return precios
+	}
+
+	nuevos, err := cotizar(ctx, precios.FlightOffers)
+	if err != nil || len(nuevos.FlightOffers) == 0 {
+		// Sin el nuevo total no se puede reservar con las maletas
+		fmt.Println("Error al cotizar el equipaje adicional, se continúa sin él:", err)
+		precios.FlightOffers = originales
+		return precios
+	}
+
+	for _, offer := range nuevos.FlightOffers {
+		fmt.Println("El precio total con equipaje es de: ", offer.Price.GrandTotal)
+	}
+	return nuevos
+}
+
+func rutaSegmento(offer FlightOffer, segmentId string) string {
+	for _, itinerario := range offer.Itineraries {
+		for _, segmento := range itinerario.Segments {
+			if segmento.Id == segmentId {
+				return segmento.Departure.IataCode + "-" + segmento.Arrival.IataCode
+			}
+		}
+	}
+	return ""
+}
+
+func clavesOrdenadas(opciones map[string]ServiceOption) []string {
+	claves := make([]string, 0, len(opciones))
+	for clave := range opciones {
+		claves = append(claves, clave)
+	}
+	sort.Slice(claves, func(i, j int) bool {
+		a, errA := strconv.Atoi(claves[i])
+		b, errB := strconv.Atoi(claves[j])
+		if errA != nil || errB != nil {
+			return claves[i] < claves[j]
+		}
+		return a < b
+	})
+	return claves
+}
+
+func contiene(lista []string, valor string) bool {
+	for _, elemento := range lista {
+		if elemento == valor {
+			return true
+		}
+	}
+	return false
+}
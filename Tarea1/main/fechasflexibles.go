@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+type CeldaCalendario struct {
+	FechaSalida  string       `json:"departureDate"`
+	FechaRegreso string       `json:"returnDate,omitempty"`
+	Precio       string       `json:"price,omitempty"`
+	Moneda       string       `json:"currency,omitempty"`
+	Oferta       *FlightOffer `json:"offer,omitempty"`
+	Error        string       `json:"error,omitempty"`
+}
+
+type CalendarioPrecios struct {
+	FechasSalida  []string          `json:"departureDates"`
+	FechasRegreso []string          `json:"returnDates,omitempty"`
+	Celdas        []CeldaCalendario `json:"cells"`
+}
+
+// Muestra la oferta más barata por fecha alrededor de las fechas pedidas
+func VerCalendarioPrecios(ctx context.Context) error {
+	var origen, destino, fecha, regreso, ventana string
+
+	fmt.Print("Aeropuerto de origen: ")
+	fmt.Scanln(&origen)
+
+	fmt.Print("Aeropuerto de destino: ")
+	fmt.Scanln(&destino)
+
+	fmt.Print("Fecha de salida (AAAA-MM-DD): ")
+	fmt.Scanln(&fecha)
+
+	fmt.Print("Fecha de regreso (AAAA-MM-DD, enter para solo ida): ")
+	fmt.Scanln(&regreso)
+
+	query := url.Values{}
+	pedirPasajeros(query)
+
+	fmt.Print("Días de flexibilidad (enter para 3): ")
+	fmt.Scanln(&ventana)
+
+	query.Set("originLocationCode", origen)
+	query.Set("destinationLocationCode", destino)
+	query.Set("departureDate", fecha)
+	if regreso != "" {
+		query.Set("returnDate", regreso)
+	}
+	if ventana != "" {
+		query.Set("window", ventana)
+	}
+
+	// Cada fecha se informa apenas se completa; el calendario completo
+	// llega en el evento final
+	var calendario CalendarioPrecios
+	terminado := false
+	err := escucharEventos(ctx, urlServidor+"/search/calendar/stream?"+query.Encode(), func(evento string, datos []byte) bool {
+		switch evento {
+		case "cell":
+			var celda CeldaCalendario
+			if err := json.Unmarshal(datos, &celda); err != nil {
+				fmt.Println("Error al deserializar el JSON:", err)
+				return true
+			}
+			mostrarCelda(celda)
+		case "summary":
+			if err := json.Unmarshal(datos, &calendario); err != nil {
+				fmt.Println("Error al deserializar el JSON:", err)
+				return false
+			}
+			terminado = true
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		fmt.Println("No se pudo obtener el calendario de precios:", err)
+		return err
+	}
+	if !terminado {
+		return fmt.Errorf("la búsqueda terminó antes de completar el calendario")
+	}
+
+	mostrarCalendarioPrecios(calendario)
+	return nil
+}
+
+// Muestra una fecha del calendario apenas se completa
+func mostrarCelda(celda CeldaCalendario) {
+	fechas := celda.FechaSalida
+	if celda.FechaRegreso != "" {
+		fechas += " / " + celda.FechaRegreso
+	}
+	switch {
+	case celda.Error != "":
+		fmt.Printf("%s: error (%s)\n", fechas, celda.Error)
+	case celda.Precio == "":
+		fmt.Printf("%s: sin ofertas\n", fechas)
+	default:
+		fmt.Printf("%s: %s %s\n", fechas, celda.Precio, celda.Moneda)
+	}
+}
+
+// Dibuja el calendario como una matriz de salida por regreso. En viajes
+// solo de ida se muestra una fila por fecha. El precio más bajo se marca
+// con un asterisco
+func mostrarCalendarioPrecios(calendario CalendarioPrecios) {
+	minimo := -1.0
+	celdas := make(map[string]CeldaCalendario)
+	for _, celda := range calendario.Celdas {
+		celdas[celda.FechaSalida+"|"+celda.FechaRegreso] = celda
+		if precio, err := strconv.ParseFloat(celda.Precio, 64); err == nil && (minimo < 0 || precio < minimo) {
+			minimo = precio
+		}
+	}
+
+	texto := func(celda CeldaCalendario, ok bool) string {
+		switch {
+		case !ok:
+			return ""
+		case celda.Error != "":
+			return "error"
+		case celda.Precio == "":
+			return "-"
+		}
+		if precio, err := strconv.ParseFloat(celda.Precio, 64); err == nil && precio == minimo {
+			return celda.Precio + " *"
+		}
+		return celda.Precio
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	fmt.Println("Calendario de precios:")
+
+	if len(calendario.FechasRegreso) == 0 {
+		table.SetHeader([]string{"SALIDA", "PRECIO", "VUELO"})
+		for _, salida := range calendario.FechasSalida {
+			celda, ok := celdas[salida+"|"]
+			vuelo := ""
+			if celda.Oferta != nil && len(celda.Oferta.Itineraries) > 0 && len(celda.Oferta.Itineraries[0].Segments) > 0 {
+				segmento := celda.Oferta.Itineraries[0].Segments[0]
+				vuelo = segmento.CarrierCode + segmento.Number
+			}
+			table.Append([]string{salida, texto(celda, ok), vuelo})
+		}
+		table.Render()
+		return
+	}
+
+	table.SetHeader(append([]string{"SALIDA \\ REGRESO"}, calendario.FechasRegreso...))
+	for _, salida := range calendario.FechasSalida {
+		fila := []string{salida}
+		for _, regreso := range calendario.FechasRegreso {
+			celda, ok := celdas[salida+"|"+regreso]
+			fila = append(fila, texto(celda, ok))
+		}
+		table.Append(fila)
+	}
+	table.Render()
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// Descarga el itinerario imprimible de una reserva en formato HTML o PDF
+func DescargarItinerario(ctx context.Context) error {
+	var id, formato, archivo string
+
+	fmt.Print("Ingrese el ID de la Reserva: ")
+	fmt.Scanln(&id)
+
+	fmt.Print("Formato (html/pdf): ")
+	fmt.Scanln(&formato)
+	if formato != "html" && formato != "pdf" {
+		fmt.Println("Formato no válido. Intente de nuevo.")
+		return fmt.Errorf("formato %q no válido", formato)
+	}
+
+	fmt.Printf("Nombre del archivo (enter para itinerario.%s): ", formato)
+	fmt.Scanln(&archivo)
+	if archivo == "" {
+		archivo = "itinerario." + formato
+	}
+
+	apiUrl := fmt.Sprintf(urlServidor+"/booking/%s/itinerary.%s", url.PathEscape(id), formato)
+
+	resp, err := obtenerURL(ctx, apiUrl)
+	if err != nil {
+		fmt.Println("Error al hacer la solicitud HTTP:", err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		fmt.Println("No se pudo obtener el itinerario:", string(respBody))
+		return fmt.Errorf("estado %d", resp.StatusCode)
+	}
+
+	if err := os.WriteFile(archivo, respBody, 0644); err != nil {
+		fmt.Println("Error al guardar el archivo:", err)
+		return err
+	}
+
+	fmt.Println("Itinerario guardado en", archivo)
+	return nil
+}
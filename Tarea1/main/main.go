@@ -0,0 +1,832 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+type Departure struct {
+	IataCode string `json:"iataCode"`
+	At       string `json:"at"`
+}
+
+type Arrival struct {
+	IataCode string `json:"iataCode"`
+	At       string `json:"at"`
+}
+
+type Aircraft struct {
+	Code string `json:"code"`
+}
+
+type Segment struct {
+	Departure       Departure `json:"departure"`
+	Arrival         Arrival   `json:"arrival"`
+	CarrierCode     string    `json:"carrierCode"`
+	Number          string    `json:"number"`
+	Aircraft        Aircraft  `json:"aircraft"`
+	Duration        string    `json:"duration"`
+	Id              string    `json:"id"`
+	NumberOfStops   int       `json:"numberOfStops"`
+	BlacklistedInEU bool      `json:"blacklistedInEU"`
+}
+
+type Itinerary struct {
+	Duration string    `json:"duration"`
+	Segments []Segment `json:"segments"`
+}
+
+type Fee struct {
+	Amount string `json:"amount"`
+	Type   string `json:"type"`
+}
+
+type Price struct {
+	Currency           string              `json:"currency"`
+	Total              string              `json:"total"`
+	Base               string              `json:"base"`
+	Fees               []Fee               `json:"fees"`
+	GrandTotal         string              `json:"grandTotal"`
+	AdditionalServices []AdditionalService `json:"additionalServices,omitempty"`
+}
+
+type PricingOptions struct {
+	FareType                []string `json:"fareType"`
+	IncludedCheckedBagsOnly bool     `json:"includedCheckedBagsOnly"`
+}
+
+type AdditionalService struct {
+	Amount string `json:"amount"`
+	Type   string `json:"type"`
+}
+
+type ChargeableCheckedBags struct {
+	Quantity   int    `json:"quantity"`
+	Weight     int    `json:"weight,omitempty"`
+	WeightUnit string `json:"weightUnit,omitempty"`
+}
+
+type SegmentAdditionalServices struct {
+	ChargeableCheckedBags *ChargeableCheckedBags `json:"chargeableCheckedBags,omitempty"`
+	OtherServices         []string               `json:"otherServices,omitempty"`
+	ChargeableSeatNumber  string                 `json:"chargeableSeatNumber,omitempty"`
+}
+
+type FareDetailsBySegment struct {
+	SegmentId           string `json:"segmentId"`
+	Cabin               string `json:"cabin"`
+	FareBasis           string `json:"fareBasis"`
+	BrandedFare         string `json:"brandedFare"`
+	Class               string `json:"class"`
+	IncludedCheckedBags struct {
+		Quantity   int    `json:"quantity"`
+		Weight     int    `json:"weight,omitempty"`
+		WeightUnit string `json:"weightUnit,omitempty"`
+	} `json:"includedCheckedBags"`
+	AdditionalServices *SegmentAdditionalServices `json:"additionalServices,omitempty"`
+}
+
+type TravelerPricing struct {
+	TravelerId        string `json:"travelerId"`
+	FareOption        string `json:"fareOption"`
+	TravelerType      string `json:"travelerType"`
+	AssociatedAdultId string `json:"associatedAdultId,omitempty"`
+	Price             struct {
+		Currency   string `json:"currency"`
+		Total      string `json:"total"`
+		Base       string `json:"base"`
+		Fees       []Fee  `json:"fees"`
+		GrandTotal string `json:"grandTotal"`
+	} `json:"price"`
+	FareDetailsBySegment []FareDetailsBySegment `json:"fareDetailsBySegment"`
+	AdditionalServices   []AdditionalService    `json:"additionalServices"`
+}
+type FlightOffer struct {
+	Type                     string                `json:"type"`
+	Id                       string                `json:"id"`
+	Source                   string                `json:"source"`
+	InstantTicketingRequired bool                  `json:"instantTicketingRequired"`
+	NonHomogeneous           bool                  `json:"nonHomogeneous"`
+	OneWay                   bool                  `json:"oneWay"`
+	LastTicketingDate        string                `json:"lastTicketingDate"`
+	LastTicketingDateTime    string                `json:"lastTicketingDateTime,omitempty"`
+	NumberOfBookableSeats    int                   `json:"numberOfBookableSeats"`
+	Itineraries              []Itinerary           `json:"itineraries"`
+	Price                    Price                 `json:"price"`
+	PricingOptions           PricingOptions        `json:"pricingOptions"`
+	ValidatingAirlineCodes   []string              `json:"validatingAirlineCodes"`
+	TravelerPricings         []TravelerPricing     `json:"travelerPricings"`
+	Emisiones                *EmisionesCO2         `json:"co2Emissions,omitempty"`
+	Politica                 *CumplimientoPolitica `json:"policy,omitempty"`
+	Proveedor                string                `json:"provider,omitempty"`
+	IdProveedor              string                `json:"providerOfferId,omitempty"`
+}
+
+// Emisiones de CO2 estimadas por el servidor para una oferta
+type EmisionesCO2 struct {
+	Kilogramos  float64 `json:"kg"`
+	PorPasajero float64 `json:"kgPerPassenger"`
+	DistanciaKm float64 `json:"distanceKm"`
+}
+
+type FlightOffersPricing struct {
+	Data struct {
+		Type         string        `json:"type"`
+		FlightOffers []FlightOffer `json:"flightOffers"`
+	} `json:"data"`
+}
+
+type ServicePrice struct {
+	Amount       string `json:"amount"`
+	CurrencyCode string `json:"currencyCode"`
+}
+
+type ServiceOption struct {
+	Quantity               int          `json:"quantity,omitempty"`
+	Weight                 int          `json:"weight,omitempty"`
+	WeightUnit             string       `json:"weightUnit,omitempty"`
+	Name                   string       `json:"name"`
+	Price                  ServicePrice `json:"price"`
+	BookableByAllTravelers bool         `json:"bookableByAllTravelers"`
+	TravelerIds            []string     `json:"travelerIds"`
+	SegmentIds             []string     `json:"segmentIds"`
+}
+
+type Included struct {
+	Bags          map[string]ServiceOption `json:"bags,omitempty"`
+	OtherServices map[string]ServiceOption `json:"other-services,omitempty"`
+}
+
+type PricingResponse struct {
+	FlightOffers []FlightOffer     `json:"flightOffers"`
+	Included     Included          `json:"included"`
+	Condiciones  []CondicionTarifa `json:"conditions,omitempty"`
+}
+
+type AssociatedRecords struct {
+	Reference        string `json:"reference"`
+	CreationDate     string `json:"creationDate"`
+	OriginSystemCode string `json:"originSystemCode"`
+	FlightOfferId    string `json:"flightOfferId"`
+}
+
+type Phones struct {
+	DeviceType         string `json:"deviceType"`
+	CountryCallingCode string `json:"countryCallingCode"`
+	Number             string `json:"number"`
+}
+
+type Travelers struct {
+	Id          string `json:"id"`
+	DateOfBirth string `json:"dateOfBirth"`
+	Gender      string `json:"gender"`
+	Name        struct {
+		FirstName string `json:"firstName"`
+		LastName  string `json:"lastName"`
+	} `json:"name"`
+	Contact struct {
+		Phones       []Phones `json:"phones"`
+		EmailAddress string   `json:"emailAddress"`
+	} `json:"contact"`
+}
+
+type FlightBooking struct {
+	Data struct {
+		Type         string        `json:"type"`
+		FlightOffers []FlightOffer `json:"flightOffers"`
+		Travelers    []Travelers   `json:"travelers"`
+	} `json:"data"`
+	MotivoAprobacion string `json:"approvalReason,omitempty"`
+}
+
+type Booking struct {
+	Data struct {
+		Type              string              `json:"type"`
+		Id                string              `json:"id"`
+		QueuingOfficeId   string              `json:"queuingOfficeId"`
+		AssociatedRecords []AssociatedRecords `json:"AssociatedRecords"`
+		FlightOffers      []FlightOffer       `json:"flightOffers"`
+		Travelers         []Travelers         `json:"travelers"`
+	} `json:"data"`
+}
+
+type Reserva struct {
+	Type              string `json:"type"`
+	ID                string `json:"id"`
+	QueuingOfficeID   string `json:"queuingOfficeId"`
+	AssociatedRecords []struct {
+		Reference        string `json:"reference"`
+		CreationDate     string `json:"creationDate"`
+		OriginSystemCode string `json:"originSystemCode"`
+		FlightOfferId    string `json:"flightOfferId"`
+	} `json:"AssociatedRecords"`
+	FlightOffers []FlightOffer `json:"flightOffers"`
+	Travelers    []Travelers   `json:"travelers"`
+}
+
+// URL base del servidor goTravel
+var urlServidor string
+
+func main() {
+	porDefecto := os.Getenv("GOTRAVEL_URL")
+	if porDefecto == "" {
+		porDefecto = "http://localhost:5000"
+	}
+	flag.StringVar(&urlServidor, "server", porDefecto, "URL base del servidor goTravel")
+	flag.StringVar(&claveAgencia, "api-key", os.Getenv("GOTRAVEL_API_KEY"), "clave de la agencia en el servidor goTravel")
+	flag.Parse()
+
+	apagarTrazas, err := iniciarTrazas(context.Background())
+	if err != nil {
+		fmt.Println("Error al iniciar las trazas:", err)
+		return
+	}
+
+	fmt.Println("Bienvenido a goTravel!")
+	for {
+		fmt.Println("\nMenú:")
+		fmt.Println("1. Realizar búsqueda.")
+		fmt.Println("2. Obtener reserva.")
+		fmt.Println("3. Descargar calendario de una reserva.")
+		fmt.Println("4. Descargar itinerario de una reserva.")
+		fmt.Println("5. Calendario de precios con fechas flexibles.")
+		fmt.Println("6. Salir")
+		fmt.Print("Ingrese una opción: ")
+		var opcion string
+		fmt.Scanln(&opcion)
+
+		// Cada opción del menú es una traza que agrupa sus llamadas al servidor
+		ctx, span := tracer.Start(context.Background(), "opcion "+opcion)
+
+		switch opcion {
+		case "1":
+			reservarVuelo(ctx)
+		case "2":
+			ObtenerReserva(ctx)
+		case "3":
+			DescargarCalendario(ctx)
+		case "4":
+			DescargarItinerario(ctx)
+		case "5":
+			VerCalendarioPrecios(ctx)
+		case "6":
+			span.End()
+			apagarTrazas(context.Background())
+			fmt.Println("¡Hasta luego!")
+			os.Exit(0)
+		default:
+			fmt.Println("Opción no válida. Intente de nuevo.")
+		}
+
+		span.End()
+	}
+}
+
+func reservarVuelo(ctx context.Context) {
+	respuesta := realizarBusqueda(ctx)
+	if respuesta == nil {
+		return
+	}
+	var vuelo int
+	fmt.Print("Seleccione un vuelo (ingrese 0 para realizar nueva búsqueda): ")
+	fmt.Scanln(&vuelo)
+
+	if vuelo == 0 {
+		// El cliente eligió realizar una nueva búsqueda
+		return // Vuelve al inicio del bucle
+	}
+	precios := obtenerPrecio(ctx, string(respuesta), vuelo)
+	if len(precios.FlightOffers) == 0 {
+		return
+	}
+	mostrarEquipaje(precios)
+	precios = seleccionarEquipaje(ctx, precios)
+	precios = seleccionarAsientos(ctx, precios)
+	mostrarCondiciones(precios)
+	if !confirmarReserva() {
+		fmt.Println("Reserva cancelada.")
+		return
+	}
+	motivo, ok := pedirMotivoAprobacion(precios)
+	if !ok {
+		fmt.Println("Reserva cancelada.")
+		return
+	}
+	reserva := RealizarReserva(ctx, precios.FlightOffers, vuelo, motivo)
+	if reserva != "" {
+		fmt.Println("Reserva creada con éxito: ", reserva)
+	}
+}
+
+func realizarBusqueda(ctx context.Context) []byte {
+	var origen, destino, fecha string
+
+	fmt.Print("Aeropuerto de origen: ")
+	fmt.Scanln(&origen)
+
+	fmt.Print("Aeropuerto de destino: ")
+	fmt.Scanln(&destino)
+
+	fmt.Print("Fecha de salida (AAAA-MM-DD): ")
+	fmt.Scanln(&fecha)
+
+	query := url.Values{}
+	query.Set("originLocationCode", origen)
+	query.Set("destinationLocationCode", destino)
+	query.Set("departureDate", fecha)
+	pedirPasajeros(query)
+
+	var orden string
+	fmt.Print("Ordenar por precio o CO2 (p/c, enter para no ordenar): ")
+	fmt.Scanln(&orden)
+	switch strings.ToLower(orden) {
+	case "p":
+		query.Set("sort", "price")
+	case "c":
+		query.Set("sort", "co2")
+	}
+
+	// Las ofertas de cada proveedor se muestran apenas llegan. Al final se
+	// muestra la lista combinada, que es la que se usa para elegir el vuelo
+	var resultado ResultadoBusqueda
+	terminada := false
+	err := escucharEventos(ctx, urlServidor+"/search/stream?"+query.Encode(), func(evento string, datos []byte) bool {
+		switch evento {
+		case "offers":
+			var parcial OfertasProveedor
+			if err := json.Unmarshal(datos, &parcial); err != nil {
+				fmt.Println("Error al deserializar el JSON:", err)
+				return true
+			}
+			fmt.Printf("%d ofertas de %s:\n", len(parcial.Data), parcial.Provider)
+			mostrarOfertas(parcial.Data)
+		case "warning":
+			var advertencia struct {
+				Provider string `json:"provider"`
+				Error    string `json:"error"`
+			}
+			json.Unmarshal(datos, &advertencia)
+			fmt.Printf("Advertencia, resultados parciales: %s no respondió (%s)\n", advertencia.Provider, advertencia.Error)
+		case "error":
+			fmt.Println("No se pudo realizar la búsqueda:", string(datos))
+			return false
+		case "summary":
+			if err := json.Unmarshal(datos, &resultado); err != nil {
+				fmt.Println("Error al deserializar el JSON:", err)
+				return false
+			}
+			terminada = true
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		fmt.Println("No se pudo realizar la búsqueda:", err)
+		return nil
+	}
+	if !terminada {
+		return nil
+	}
+
+	var flightOffers []FlightOffer
+	if err := json.Unmarshal(resultado.Data, &flightOffers); err != nil {
+		fmt.Println("Error al deserializar el JSON:", err)
+		return nil
+	}
+	fmt.Println("Resultados combinados:")
+	mostrarOfertas(flightOffers)
+
+	return resultado.Data
+
+}
+
+func mostrarOfertas(flightOffers []FlightOffer) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"VUELO", "NÚMERO", "HORA DE SALIDA", "HORA DE LLEGADA", "AVIÓN", "PRECIO TOTAL", "CO2 (KG)", "POLÍTICA", "EMITIR HASTA"})
+
+	for _, offer := range flightOffers {
+		// Añadir una fila a la tabla
+		dateTimeStr := offer.Itineraries[0].Segments[0].Departure.At
+		dateTimeStr2 := offer.Itineraries[0].Segments[0].Arrival.At
+		layout := "2006-01-02T15:04:05"
+		parsedTime, err := time.Parse(layout, dateTimeStr)
+		if err != nil {
+			fmt.Println("Error al analizar la fecha y hora:", err)
+		}
+		parsedTime2, err := time.Parse(layout, dateTimeStr2)
+		if err != nil {
+			fmt.Println("Error al analizar la fecha y hora:", err)
+		}
+
+		// Formatear el objeto de tiempo en el formato deseado
+		formattedTime := parsedTime.Format("15:04")
+		formattedTime2 := parsedTime2.Format("15:04")
+		emisiones := "-"
+		if offer.Emisiones != nil {
+			emisiones = strconv.FormatFloat(offer.Emisiones.Kilogramos, 'f', 1, 64)
+		}
+		table.Append([]string{
+			offer.Id,
+			offer.Itineraries[0].Segments[0].CarrierCode + offer.Itineraries[0].Segments[0].Number,
+			formattedTime,
+			formattedTime2,
+			offer.Itineraries[0].Segments[0].CarrierCode + offer.Itineraries[0].Segments[0].Aircraft.Code,
+			offer.Price.Total,
+			emisiones,
+			describirPolitica(offer.Politica),
+			offer.LastTicketingDate,
+		})
+	}
+
+	// Renderizar la tabla
+	table.Render()
+}
+
+func obtenerPrecio(ctx context.Context, flight string, numero_vuelo int) PricingResponse {
+
+	// Convertir el JSON original a una lista de mapas
+	var flightOffers []FlightOffer
+	if err := json.Unmarshal([]byte(flight), &flightOffers); err != nil {
+		fmt.Println("Error al deserializar el JSON:", err)
+		return PricingResponse{}
+	}
+
+	// Solo se cotiza el vuelo seleccionado
+	var vuelo []FlightOffer
+	var id_vuelo string = strconv.Itoa(numero_vuelo)
+	for _, offer := range flightOffers {
+		if id_vuelo == offer.Id {
+			vuelo = append(vuelo, offer)
+		}
+	}
+	if len(vuelo) == 0 {
+		fmt.Println("No existe un vuelo con el número", numero_vuelo)
+		return PricingResponse{}
+	}
+
+	precios, err := cotizar(ctx, vuelo)
+	if err != nil {
+		fmt.Println("Error al obtener el precio:", err)
+		return PricingResponse{}
+	}
+
+	for _, offer := range precios.FlightOffers {
+		fmt.Println("El precio total final es de: ", offer.Price.GrandTotal)
+		if offer.LastTicketingDate != "" {
+			fmt.Println("Último día para emitir los boletos: ", offer.LastTicketingDate)
+		}
+	}
+	return precios
+}
+
+// Envía las ofertas al servidor para obtener su precio confirmado y las
+// opciones de equipaje disponibles
+func cotizar(ctx context.Context, flightOffers []FlightOffer) (PricingResponse, error) {
+
+	// Crear una instancia de FlightOffersPricing y asignar los vuelos a la estructura
+	flightOffersPricing := FlightOffersPricing{
+		Data: struct {
+			Type         string        `json:"type"`
+			FlightOffers []FlightOffer `json:"flightOffers"`
+		}{
+			Type:         "flight-offers-pricing",
+			FlightOffers: flightOffers,
+		},
+	}
+
+	// Convertir la estructura FlightOffersPricing a JSON
+	resultJSON, err := json.Marshal(flightOffersPricing)
+	if err != nil {
+		return PricingResponse{}, err
+	}
+
+	// Crear una solicitud HTTP POST
+	req, err := http.NewRequestWithContext(ctx, "POST", urlServidor+"/pricing", bytes.NewBuffer(resultJSON))
+	if err != nil {
+		return PricingResponse{}, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	// Enviar la solicitud
+	resp, err := clienteHTTP.Do(req)
+	if err != nil {
+		return PricingResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	// Leer la respuesta
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return PricingResponse{}, err
+	}
+
+	// Por ejemplo, una oferta que ya pasó su último día de emisión
+	if resp.StatusCode != http.StatusOK {
+		var fallo struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(respBody, &fallo) == nil && fallo.Error != "" {
+			return PricingResponse{}, fmt.Errorf("%s", fallo.Error)
+		}
+		return PricingResponse{}, fmt.Errorf("estado %d: %s", resp.StatusCode, respBody)
+	}
+
+	var precios PricingResponse
+	if err := json.Unmarshal(respBody, &precios); err != nil {
+		return PricingResponse{}, err
+	}
+	return precios, nil
+}
+
+// Copia independiente de las ofertas, para deshacer los servicios
+// agregados si la nueva cotización falla
+func copiarOfertas(ofertas []FlightOffer) []FlightOffer {
+	datos, err := json.Marshal(ofertas)
+	if err != nil {
+		return nil
+	}
+	var copia []FlightOffer
+	if err := json.Unmarshal(datos, &copia); err != nil {
+		return nil
+	}
+	return copia
+}
+
+func RealizarReserva(ctx context.Context, flight []FlightOffer, numero_vuelo int, motivo string) string {
+
+	var pasajeros []Travelers
+
+	// Se pide un pasajero por cada precio de la oferta, con el mismo id y
+	// tipo que asignó la búsqueda
+	if len(flight) == 0 {
+		return ""
+	}
+	for _, pricing := range flight[0].TravelerPricings {
+		var fecha, nombre, apellido, sexo, correo, telefono string
+
+		fmt.Printf("Datos del pasajero %s (%s):\n", pricing.TravelerId, describirPasajero(pricing))
+		fmt.Print("Fecha Nacimiento (AAAA-MM-DD): ")
+		fmt.Scanln(&fecha)
+
+		fmt.Print("Nombre: ")
+		fmt.Scanln(&nombre)
+
+		fmt.Print("Apellido: ")
+		fmt.Scanln(&apellido)
+
+		fmt.Print("Sexo: ")
+		fmt.Scanln(&sexo)
+
+		fmt.Print("Correo: ")
+		fmt.Scanln(&correo)
+
+		// El teléfono incluye el código de país, por ejemplo +56912345678
+		for len(telefono) < 4 {
+			fmt.Print("Telefono: ")
+			fmt.Scanln(&telefono)
+		}
+
+		traveler := Travelers{
+			Id:          pricing.TravelerId,
+			DateOfBirth: fecha,
+			Gender:      sexo,
+			Name: struct {
+				FirstName string `json:"firstName"`
+				LastName  string `json:"lastName"`
+			}{
+				FirstName: nombre,
+				LastName:  apellido,
+			},
+			Contact: struct {
+				Phones       []Phones `json:"phones"`
+				EmailAddress string   `json:"emailAddress"`
+			}{
+				Phones: []Phones{
+					{
+						DeviceType:         "MOBILE",      // Puedes establecer esto según corresponda
+						CountryCallingCode: telefono[1:3], // Puedes establecer esto según corresponda
+						Number:             telefono[3:],  // Usar el número de teléfono recopilado
+					},
+				},
+				EmailAddress: correo,
+			},
+		}
+
+		pasajeros = append(pasajeros, traveler)
+	}
+
+	// Convertir el JSON original a una lista de mapas
+
+	// Crear una instancia de FlightBooking y asignar pasajero
+	flightbooking := FlightBooking{
+		Data: struct {
+			Type         string        `json:"type"`
+			FlightOffers []FlightOffer `json:"flightOffers"`
+			Travelers    []Travelers   `json:"travelers"`
+		}{
+			Type:         "flight-order",
+			FlightOffers: flight,
+			Travelers:    pasajeros,
+		},
+		MotivoAprobacion: motivo,
+	}
+
+	// Convertir la estructura FlightOffersPricing a JSON
+	resultJSON, err := json.Marshal(flightbooking)
+	if err != nil {
+		fmt.Println("Error al serializar el JSON:", err)
+		return ""
+	}
+
+	// La misma clave se usa en todos los reintentos para que el servidor no
+	// cree la reserva dos veces
+	clave := generarClave()
+
+	for intento := 1; intento <= intentosReserva; intento++ {
+		respBody, estado, err := enviarReserva(ctx, resultJSON, clave)
+		if err == nil && estado == http.StatusAccepted {
+			mostrarAprobacionPendiente(respBody)
+			return ""
+		}
+		if err == nil && estado == http.StatusOK {
+			return leerReservaCreada(respBody)
+		}
+		if err == nil && estado != http.StatusConflict {
+			mostrarReservaRechazada(respBody, estado)
+			return ""
+		}
+		if err != nil {
+			fmt.Printf("Error al enviar la reserva (intento %d de %d): %v\n", intento, intentosReserva, err)
+		} else {
+			fmt.Printf("La reserva sigue en proceso (intento %d de %d)\n", intento, intentosReserva)
+		}
+		if intento < intentosReserva {
+			time.Sleep(time.Duration(intento) * 2 * time.Second)
+		}
+	}
+	return ""
+}
+
+// Lee el id de la reserva creada y muestra el aviso del servidor si la
+// reserva quedo marcada para revision
+func leerReservaCreada(respBody []byte) string {
+	var reserva struct {
+		Id          string `json:"id"`
+		Advertencia string `json:"warning"`
+	}
+	if err := json.Unmarshal(respBody, &reserva); err != nil {
+		fmt.Println("Error al deserializar el JSON:", err)
+		return ""
+	}
+	if reserva.Advertencia != "" {
+		fmt.Println("Aviso:", reserva.Advertencia)
+	}
+	return reserva.Id
+}
+
+// Muestra el error del servidor cuando la reserva no se pudo crear, por
+// ejemplo pasajeros no validos o una oferta que el proveedor rechazo
+func mostrarReservaRechazada(respBody []byte, estado int) {
+	var fallo struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(respBody, &fallo) == nil && fallo.Error != "" {
+		fmt.Println("No se pudo crear la reserva:", fallo.Error)
+		return
+	}
+	fmt.Printf("No se pudo crear la reserva (estado %d): %s\n", estado, respBody)
+}
+
+const (
+	intentosReserva = 3
+	tiempoReserva   = 60 * time.Second
+)
+
+func enviarReserva(ctx context.Context, resultJSON []byte, clave string) ([]byte, int, error) {
+	ctx, cancelar := context.WithTimeout(ctx, tiempoReserva)
+	defer cancelar()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", urlServidor+"/booking", bytes.NewBuffer(resultJSON))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", clave)
+
+	// Enviar la solicitud
+	resp, err := clienteHTTP.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	// Leer la respuesta
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	return respBody, resp.StatusCode, nil
+}
+
+// Genera una clave de idempotencia aleatoria para una reserva
+func generarClave() string {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(bytes)
+}
+
+func ObtenerReserva(ctx context.Context) error {
+	var id string
+
+	fmt.Print("Ingrese el ID de la Reserva: ")
+	fmt.Scanln(&id)
+
+	url := fmt.Sprintf(urlServidor+"/booking?id=%s", id)
+
+	resp, err := obtenerURL(ctx, url)
+	if err != nil {
+		fmt.Println("Error al hacer la solicitud HTTP:", err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var reserva Reserva
+	if err := json.Unmarshal([]byte(respBody), &reserva); err != nil {
+		fmt.Println("Error al analizar JSON:", err)
+	}
+
+	table1 := tablewriter.NewWriter(os.Stdout)
+	fmt.Println("Resultado:")
+	table1.SetHeader([]string{"NÚMERO", "HORA DE SALIDA", "HORA DE LLEGADA", "AVIÓN", "PRECIO TOTAL", "EMITIR HASTA"})
+	// Renderizar la tabla
+	for _, offer := range reserva.FlightOffers {
+		// Añadir una fila a la tabla
+		dateTimeStr := offer.Itineraries[0].Segments[0].Departure.At
+		dateTimeStr2 := offer.Itineraries[0].Segments[0].Arrival.At
+		layout := "2006-01-02T15:04:05"
+		parsedTime, err := time.Parse(layout, dateTimeStr)
+		if err != nil {
+			fmt.Println("Error al analizar la fecha y hora:", err)
+		}
+		parsedTime2, err := time.Parse(layout, dateTimeStr2)
+		if err != nil {
+			fmt.Println("Error al analizar la fecha y hora:", err)
+		}
+
+		// Formatear el objeto de tiempo en el formato deseado
+		formattedTime := parsedTime.Format("15:04")
+		formattedTime2 := parsedTime2.Format("15:04")
+		table1.Append([]string{
+			offer.Itineraries[0].Segments[0].CarrierCode + offer.Itineraries[0].Segments[0].Number,
+			formattedTime,
+			formattedTime2,
+			offer.Itineraries[0].Segments[0].CarrierCode + offer.Itineraries[0].Segments[0].Aircraft.Code,
+			offer.Price.Total,
+			offer.LastTicketingDate,
+		})
+	}
+
+	// Renderizar la tabla
+	table1.Render()
+
+	table := tablewriter.NewWriter(os.Stdout)
+	fmt.Println("Pasajeros:")
+	table.SetHeader([]string{"Nombre", "Apellido"})
+	// Renderizar la tabla
+	for _, traveler := range reserva.Travelers {
+
+		table.Append([]string{
+			traveler.Name.FirstName,
+			traveler.Name.LastName,
+		})
+	}
+
+	// Renderizar la tabla
+	table.Render()
+
+	return nil
+
+}
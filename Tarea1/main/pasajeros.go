@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// Pregunta la cantidad de pasajeros por tipo y la agrega a la consulta.
+// Los mayores (60 años o más) y los infantes en brazos son opcionales
+func pedirPasajeros(query url.Values) {
+	var adultos, mayores, ninos, infantes string
+
+	fmt.Print("Cantidad de adultos: ")
+	fmt.Scanln(&adultos)
+
+	fmt.Print("Cantidad de mayores de 60 años (enter para 0): ")
+	fmt.Scanln(&mayores)
+
+	fmt.Print("Cantidad de niños de 2 a 11 años (enter para 0): ")
+	fmt.Scanln(&ninos)
+
+	fmt.Print("Cantidad de infantes menores de 2 años en brazos (enter para 0): ")
+	fmt.Scanln(&infantes)
+
+	query.Set("adults", adultos)
+	for nombre, valor := range map[string]string{"seniors": mayores, "children": ninos, "infants": infantes} {
+		if valor != "" && valor != "0" {
+			query.Set(nombre, valor)
+		}
+	}
+}
+
+var nombresTipoPasajero = map[string]string{
+	"ADULT":         "adulto",
+	"SENIOR":        "mayor",
+	"CHILD":         "niño",
+	"HELD_INFANT":   "infante en brazos",
+	"SEATED_INFANT": "infante con asiento",
+}
+
+// Describe el tipo de un pasajero de la oferta, indicando el adulto que
+// lleva a cada infante
+func describirPasajero(pricing TravelerPricing) string {
+	nombre, ok := nombresTipoPasajero[pricing.TravelerType]
+	if !ok {
+		nombre = pricing.TravelerType
+	}
+	if pricing.AssociatedAdultId != "" {
+		nombre += ", con el pasajero " + pricing.AssociatedAdultId
+	}
+	return nombre
+}
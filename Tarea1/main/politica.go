@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Resultado de la politica de viajes que el servidor agrega a cada oferta
+type CumplimientoPolitica struct {
+	Cumple       bool     `json:"compliant"`
+	Infracciones []string `json:"violations,omitempty"`
+}
+
+func describirPolitica(cumplimiento *CumplimientoPolitica) string {
+	switch {
+	case cumplimiento == nil:
+		return "-"
+	case cumplimiento.Cumple:
+		return "OK"
+	}
+	return "NO (" + strconv.Itoa(len(cumplimiento.Infracciones)) + ")"
+}
+
+// Si la oferta cotizada no cumple la politica muestra las infracciones y
+// pide el motivo, que el servidor exige para reservarla. Devuelve false si
+// el usuario no indica un motivo
+func pedirMotivoAprobacion(precios PricingResponse) (string, bool) {
+	var infracciones []string
+	for _, offer := range precios.FlightOffers {
+		if offer.Politica != nil {
+			infracciones = append(infracciones, offer.Politica.Infracciones...)
+		}
+	}
+	if len(infracciones) == 0 {
+		return "", true
+	}
+
+	fmt.Println("La oferta no cumple la política de viajes:")
+	for _, infraccion := range infracciones {
+		fmt.Println("  -", infraccion)
+	}
+	fmt.Print("Motivo para reservarla de todas formas (enter para cancelar): ")
+	motivo := strings.TrimSpace(leerLinea())
+	return motivo, motivo != ""
+}
+
+// Las reservas fuera de la politica quedan pendientes hasta que un
+// aprobador las acepte
+func mostrarAprobacionPendiente(respBody []byte) {
+	var solicitud struct {
+		Id     string `json:"id"`
+		Expira string `json:"expiresAt"`
+	}
+	if err := json.Unmarshal(respBody, &solicitud); err != nil {
+		fmt.Println("Error al deserializar el JSON:", err)
+		return
+	}
+	fmt.Println("La reserva quedó pendiente de aprobación. Solicitud:", solicitud.Id)
+	fmt.Println("Si no se aprueba antes de", solicitud.Expira, "vencerá junto con el precio cotizado.")
+	fmt.Println("Su estado se puede consultar en", urlServidor+"/approvals/"+solicitud.Id)
+}
+
+// Lee una linea completa de la entrada. fmt.Scanln solo lee hasta el primer
+// espacio; se lee de a un byte para no consumir las lineas siguientes
+func leerLinea() string {
+	var linea []byte
+	b := make([]byte, 1)
+	for {
+		n, err := os.Stdin.Read(b)
+		if n == 0 || err != nil || b[0] == '\n' {
+			break
+		}
+		linea = append(linea, b[0])
+	}
+	return strings.TrimRight(string(linea), "\r")
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+type Penalidad struct {
+	Permitido   *bool  `json:"allowed,omitempty"`
+	Monto       string `json:"amount,omitempty"`
+	Moneda      string `json:"currency,omitempty"`
+	Nota        string `json:"note,omitempty"`
+	Desconocida bool   `json:"unknown,omitempty"`
+}
+
+type CondicionTarifa struct {
+	OfertaId   string    `json:"offerId"`
+	SegmentoId string    `json:"segmentId"`
+	Ruta       string    `json:"route"`
+	BaseTarifa string    `json:"fareBasis,omitempty"`
+	Marca      string    `json:"brandedFare,omitempty"`
+	Cambio     Penalidad `json:"change"`
+	Reembolso  Penalidad `json:"refund"`
+	NoShow     Penalidad `json:"noShow"`
+}
+
+// Muestra las condiciones de cambio, reembolso y no presentación de cada
+// segmento, junto a las notas de la tarifa
+func mostrarCondiciones(precios PricingResponse) {
+	if len(precios.Condiciones) == 0 {
+		fmt.Println("El proveedor no informó las condiciones de la tarifa.")
+		return
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	fmt.Println("Condiciones de la tarifa:")
+	table.SetHeader([]string{"SEGMENTO", "RUTA", "TARIFA", "CAMBIO", "REEMBOLSO", "NO SHOW"})
+	var notas []string
+	for _, condicion := range precios.Condiciones {
+		tarifa := condicion.Marca
+		if tarifa == "" {
+			tarifa = condicion.BaseTarifa
+		}
+		table.Append([]string{
+			condicion.SegmentoId,
+			condicion.Ruta,
+			tarifa,
+			describirPenalidad(condicion.Cambio),
+			describirPenalidad(condicion.Reembolso),
+			describirPenalidad(condicion.NoShow),
+		})
+		for _, nota := range []string{condicion.Cambio.Nota, condicion.Reembolso.Nota, condicion.NoShow.Nota} {
+			if nota != "" && !contiene(notas, nota) {
+				notas = append(notas, nota)
+			}
+		}
+	}
+	table.Render()
+
+	for _, nota := range notas {
+		fmt.Println(" -", nota)
+	}
+}
+
+func describirPenalidad(penalidad Penalidad) string {
+	switch {
+	case penalidad.Desconocida:
+		return "desconocida"
+	case penalidad.Permitido == nil && penalidad.Nota != "":
+		return "ver nota"
+	case penalidad.Permitido == nil:
+		return "sin información"
+	case !*penalidad.Permitido:
+		return "no permitido"
+	case penalidad.Monto == "" || strings.Trim(penalidad.Monto, "0.") == "":
+		return "sin costo"
+	}
+	return "hasta " + penalidad.Monto + " " + penalidad.Moneda
+}
+
+// Pide al usuario que confirme la reserva después de ver las condiciones
+func confirmarReserva() bool {
+	var respuesta string
+	fmt.Print("¿Confirmar la reserva con estas condiciones? (s/n): ")
+	fmt.Scanln(&respuesta)
+	return strings.EqualFold(respuesta, "s") || strings.EqualFold(respuesta, "si") || strings.EqualFold(respuesta, "sí")
+}
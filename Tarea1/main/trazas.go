@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+const nombreServicio = "gotravel-cli"
+
+var tracer = otel.Tracer(nombreServicio)
+
+// Cliente HTTP hacia el servidor. Propaga el contexto de traza (W3C) y
+// envia la clave de la agencia en cada solicitud
+var clienteHTTP = &http.Client{Transport: otelhttp.NewTransport(transporteAgencia{http.DefaultTransport})}
+
+// Configura OpenTelemetry segun OTEL_TRACES_EXPORTER ("otlp" o "stdout").
+// Sin exportador las trazas quedan deshabilitadas
+func iniciarTrazas(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exportador sdktrace.SpanExporter
+	var err error
+	switch os.Getenv("OTEL_TRACES_EXPORTER") {
+	case "otlp":
+		exportador, err = otlptracehttp.New(ctx)
+	case "stdout", "console":
+		exportador, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return func(context.Context) error { return nil }, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo crear el exportador de trazas: %w", err)
+	}
+
+	proveedor := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exportador),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(nombreServicio),
+		)),
+	)
+	otel.SetTracerProvider(proveedor)
+	return proveedor.Shutdown, nil
+}
+
+// Realiza una solicitud GET al servidor dentro de la traza del contexto
+func obtenerURL(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return clienteHTTP.Do(req)
+}
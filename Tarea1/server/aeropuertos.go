@@ -0,0 +1,78 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata"
+)
+
+// Datos de aeropuertos incluidos en el binario (codigo IATA, ubicacion y
+// zona horaria)
+//
+//go:embed aeropuertos.csv
+var aeropuertosCSV string
+
+type Aeropuerto struct {
+	Iata        string
+	Nombre      string
+	Ciudad      string
+	Pais        string
+	Latitud     float64
+	Longitud    float64
+	ZonaHoraria *time.Location
+}
+
+var (
+	aeropuertos      map[string]Aeropuerto
+	cargaAeropuertos sync.Once
+)
+
+func cargarAeropuertos() {
+	aeropuertos = make(map[string]Aeropuerto)
+
+	filas, err := csv.NewReader(strings.NewReader(aeropuertosCSV)).ReadAll()
+	if err != nil {
+		slog.Error("Error al leer los aeropuertos", "error", err)
+		return
+	}
+
+	// La primera fila corresponde al encabezado
+	for _, fila := range filas[1:] {
+		latitud, err := strconv.ParseFloat(fila[4], 64)
+		if err != nil {
+			slog.Error("Latitud no válida", "aeropuerto", fila[0], "error", err)
+			continue
+		}
+		longitud, err := strconv.ParseFloat(fila[5], 64)
+		if err != nil {
+			slog.Error("Longitud no válida", "aeropuerto", fila[0], "error", err)
+			continue
+		}
+		zona, err := time.LoadLocation(fila[6])
+		if err != nil {
+			slog.Error("Zona horaria no válida", "aeropuerto", fila[0], "error", err)
+			continue
+		}
+		aeropuertos[fila[0]] = Aeropuerto{
+			Iata:        fila[0],
+			Nombre:      fila[1],
+			Ciudad:      fila[2],
+			Pais:        fila[3],
+			Latitud:     latitud,
+			Longitud:    longitud,
+			ZonaHoraria: zona,
+		}
+	}
+}
+
+// Busca un aeropuerto por su codigo IATA
+func buscarAeropuerto(iata string) (Aeropuerto, bool) {
+	cargaAeropuertos.Do(cargarAeropuertos)
+	aeropuerto, ok := aeropuertos[strings.ToUpper(iata)]
+	return aeropuerto, ok
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Cabeceras con las que se identifica la agencia: los clientes envian su
+// clave y los administradores el id de la agencia que administran
+const (
+	cabeceraClaveAPI = "X-API-Key"
+	cabeceraAgencia  = "X-Agency"
+)
+
+// Moneda de las busquedas cuando la agencia no indica otra
+const monedaPorDefecto = "CLP"
+
+// Agencia de viajes que comparte el servidor. Cada una tiene sus propias
+// credenciales de Amadeus, moneda, politica de viajes y colecciones de
+// reservas en MongoDB
+type Agencia struct {
+	Id              string `json:"id"`
+	ClaveAPI        string `json:"apiKey"`
+	ClientID        string `json:"clientId"`
+	ClientSecret    string `json:"clientSecret"`
+	Moneda          string `json:"currency"`
+	ArchivoPolitica string `json:"policyFile"`
+
+	// Archivos con las credenciales, por ejemplo secretos montados. Tienen
+	// prioridad sobre clientId y clientSecret y se recargan si cambian
+	ArchivoClientID     string `json:"clientIdFile"`
+	ArchivoClientSecret string `json:"clientSecretFile"`
+
+	politica     *PoliticaViajes
+	credenciales credencialesAgencia
+}
+
+// Agencias cargadas al iniciar. Sin archivo de agencias hay una sola, la
+// agencia por defecto, que usa la configuracion global y las colecciones
+// originales
+var (
+	agencias          map[string]*Agencia
+	agenciaPorDefecto *Agencia
+)
+
+type claveContextoAgencia struct{}
+
+// Carga las agencias del archivo indicado. Sin archivo se crea solo la
+// agencia por defecto a partir de cfg
+func cargarAgencias(archivo string, cfg Configuracion) (map[string]*Agencia, *Agencia, error) {
+	if archivo == "" {
+		agencia := &Agencia{
+			ClientID:            cfg.ClientID,
+			ClientSecret:        cfg.ClientSecret,
+			Moneda:              monedaPorDefecto,
+			ArchivoPolitica:     cfg.ArchivoPolitica,
+			ArchivoClientID:     cfg.ArchivoClientID,
+			ArchivoClientSecret: cfg.ArchivoClientSecret,
+		}
+		if _, err := agencia.cargarCredenciales(); err != nil {
+			return nil, nil, err
+		}
+		politica, err := cargarPolitica(agencia.ArchivoPolitica)
+		if err != nil {
+			return nil, nil, err
+		}
+		agencia.politica = politica
+		return map[string]*Agencia{"": agencia}, agencia, nil
+	}
+
+	contenido, err := os.ReadFile(archivo)
+	if err != nil {
+		return nil, nil, fmt.Errorf("no se pudo leer el archivo de agencias: %w", err)
+	}
+	var datos struct {
+		Agencias []*Agencia `json:"agencies"`
+	}
+	if err := json.Unmarshal(contenido, &datos); err != nil {
+		return nil, nil, fmt.Errorf("archivo de agencias no válido: %w", err)
+	}
+	if len(datos.Agencias) == 0 {
+		return nil, nil, errors.New("el archivo de agencias no define ninguna agencia")
+	}
+
+	cargadas := make(map[string]*Agencia)
+	claves := make(map[string]bool)
+	for _, agencia := range datos.Agencias {
+		switch {
+		case agencia.Id == "" || strings.ContainsAny(agencia.Id, " ./$"):
+			return nil, nil, fmt.Errorf("agencia con id no válido: %q", agencia.Id)
+		case cargadas[agencia.Id] != nil:
+			return nil, nil, fmt.Errorf("la agencia %s está repetida", agencia.Id)
+		case agencia.ClaveAPI == "" || claves[agencia.ClaveAPI]:
+			return nil, nil, fmt.Errorf("la agencia %s debe tener una apiKey propia", agencia.Id)
+		}
+		if _, err := agencia.cargarCredenciales(); err != nil {
+			return nil, nil, fmt.Errorf("agencia %s: %w", agencia.Id, err)
+		}
+		if agencia.Moneda == "" {
+			agencia.Moneda = monedaPorDefecto
+		}
+		politica, err := cargarPolitica(agencia.ArchivoPolitica)
+		if err != nil {
+			return nil, nil, fmt.Errorf("agencia %s: %w", agencia.Id, err)
+		}
+		agencia.politica = politica
+		cargadas[agencia.Id] = agencia
+		claves[agencia.ClaveAPI] = true
+	}
+	return cargadas, nil, nil
+}
+
+func conAgencia(ctx context.Context, agencia *Agencia) context.Context {
+	return context.WithValue(ctx, claveContextoAgencia{}, agencia)
+}
+
+// Agencia de la solicitud. Si el contexto no trae una se usa la agencia por
+// defecto (nil cuando hay archivo de agencias)
+func agenciaDe(ctx context.Context) *Agencia {
+	if agencia, ok := ctx.Value(claveContextoAgencia{}).(*Agencia); ok {
+		return agencia
+	}
+	return agenciaPorDefecto
+}
+
+func idAgencia(ctx context.Context) string {
+	if agencia := agenciaDe(ctx); agencia != nil {
+		return agencia.Id
+	}
+	return ""
+}
+
+// Agencias ordenadas por id, para recorrerlas siempre en el mismo orden
+func listaAgencias() []*Agencia {
+	lista := make([]*Agencia, 0, len(agencias))
+	for _, agencia := range agencias {
+		lista = append(lista, agencia)
+	}
+	sort.Slice(lista, func(i, j int) bool { return lista[i].Id < lista[j].Id })
+	return lista
+}
+
+// Ejecuta una tarea en segundo plano una vez por agencia, con la agencia en
+// el contexto. Un error en una agencia no detiene a las demas
+func paraCadaAgencia(ctx context.Context, tarea func(ctx context.Context) (int, error)) (int, error) {
+	total := 0
+	var errores []error
+	for _, agencia := range listaAgencias() {
+		n, err := tarea(conAgencia(ctx, agencia))
+		total += n
+		if err != nil {
+			errores = append(errores, fmt.Errorf("agencia %q: %w", agencia.Id, err))
+		}
+	}
+	return total, errors.Join(errores...)
+}
+
+// Middleware que identifica la agencia por la cabecera X-API-Key. Sin
+// archivo de agencias todas las solicitudes usan la agencia por defecto
+func identificarAgencia() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if agenciaPorDefecto != nil {
+			c.Next()
+			return
+		}
+
+		// Se compara con todas las claves, y sus hashes para que tengan el
+		// mismo largo, de modo que el tiempo no revele cual coincide
+		recibida := sha256.Sum256([]byte(c.GetHeader(cabeceraClaveAPI)))
+		var elegida *Agencia
+		for _, agencia := range agencias {
+			esperada := sha256.Sum256([]byte(agencia.ClaveAPI))
+			if subtle.ConstantTimeCompare(esperada[:], recibida[:]) == 1 {
+				elegida = agencia
+			}
+		}
+		if elegida != nil && c.GetHeader(cabeceraClaveAPI) != "" {
+			c.Request = c.Request.WithContext(conAgencia(c.Request.Context(), elegida))
+			c.Next()
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Clave de agencia no válida (" + cabeceraClaveAPI + ")"})
+	}
+}
+
+// Middleware de las rutas de administracion: la agencia se elige con la
+// cabecera X-Agency. Se usa despues de soloAdministradores
+func agenciaAdministrada() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if agenciaPorDefecto != nil {
+			c.Next()
+			return
+		}
+
+		agencia, ok := agencias[c.GetHeader(cabeceraAgencia)]
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Indique una agencia válida en la cabecera " + cabeceraAgencia})
+			return
+		}
+		c.Request = c.Request.WithContext(conAgencia(c.Request.Context(), agencia))
+		c.Next()
+	}
+}
+
+// Colecciones de la agencia. La agencia por defecto usa las colecciones
+// originales; las demas agregan su id como sufijo
+func (a *Agencia) coleccion(nombre string) string {
+	if a == nil || a.Id == "" {
+		return nombre
+	}
+	return nombre + "_" + a.Id
+}
+
+func coleccionReservasDe(ctx context.Context) string {
+	return agenciaDe(ctx).coleccion(coleccionReservas)
+}
+
+func coleccionAprobacionesDe(ctx context.Context) string {
+	return agenciaDe(ctx).coleccion(coleccionAprobaciones)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestIdentificarAgenciaPorClave(t *testing.T) {
+	anteriores, anteriorPorDefecto := agencias, agenciaPorDefecto
+	t.Cleanup(func() { agencias, agenciaPorDefecto = anteriores, anteriorPorDefecto })
+	agenciaPorDefecto = nil
+	agencias = map[string]*Agencia{
+		"norte": {Id: "norte", ClaveAPI: "clave-norte"},
+		"sur":   {Id: "sur", ClaveAPI: "clave-sur-mas-larga"},
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", identificarAgencia(), func(c *gin.Context) { c.String(http.StatusOK, idAgencia(c.Request.Context())) })
+
+	casos := []struct {
+		clave, agencia string
+		estado         int
+	}{
+		{"clave-norte", "norte", http.StatusOK},
+		{"clave-sur-mas-larga", "sur", http.StatusOK},
+		{"clave-sur", "", http.StatusUnauthorized},
+		{"", "", http.StatusUnauthorized},
+	}
+	for _, caso := range casos {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(cabeceraClaveAPI, caso.clave)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != caso.estado || (caso.estado == http.StatusOK && w.Body.String() != caso.agencia) {
+			t.Errorf("clave %q: %d %s, se esperaba %d %s", caso.clave, w.Code, w.Body.String(), caso.estado, caso.agencia)
+		}
+	}
+}
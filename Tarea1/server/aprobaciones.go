@@ -0,0 +1,509 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const coleccionAprobaciones = "aprobaciones"
+
+// Estados de una solicitud de aprobacion. enviando indica que un aprobador
+// la acepto y la reserva se esta creando en el proveedor; incierta, que se
+// quedo en enviando y no se sabe si el proveedor creo la reserva
+const (
+	aprobacionPendiente = "pendiente"
+	aprobacionEnviando  = "enviando"
+	aprobacionAprobada  = "aprobada"
+	aprobacionRechazada = "rechazada"
+	aprobacionVencida   = "vencida"
+	aprobacionFallida   = "fallida"
+	aprobacionIncierta  = "incierta"
+)
+
+// Cada cuanto se vencen las solicitudes cuyo precio ya no es valido
+const intervaloAprobaciones = time.Minute
+
+// Tiempo tras el cual una solicitud en enviando se da por interrumpida (por
+// ejemplo, si el servidor se reinicio mientras creaba la reserva)
+const plazoEnvioAprobacion = 10 * time.Minute
+
+// Reserva fuera de la politica que espera la decision de un aprobador. El
+// cuerpo original de /booking se guarda cifrado (incluye los datos de los
+// pasajeros) y se elimina al resolver la solicitud
+type SolicitudAprobacion struct {
+	Id         string             `bson:"_id" json:"id"`
+	Agencia    string             `bson:"agencia,omitempty" json:"agency,omitempty"`
+	Estado     string             `bson:"estado" json:"status"`
+	Creada     time.Time          `bson:"creada" json:"createdAt"`
+	Expira     time.Time          `bson:"expira" json:"expiresAt"`
+	Ruta       string             `bson:"ruta" json:"route"`
+	Total      string             `bson:"total" json:"total"`
+	Aprobacion AprobacionPolitica `bson:"politica" json:"policy"`
+	Cuerpo     string             `bson:"cuerpo,omitempty" json:"-"`
+	Enviada    *time.Time         `bson:"enviada,omitempty" json:"submittedAt,omitempty"`
+	Resuelta   *time.Time         `bson:"resuelta,omitempty" json:"resolvedAt,omitempty"`
+	Comentario string             `bson:"comentario,omitempty" json:"comment,omitempty"`
+	IdReserva  string             `bson:"idReserva,omitempty" json:"bookingId,omitempty"`
+}
+
+var (
+	errAprobacionNoEncontrada = errors.New("solicitud de aprobación no encontrada")
+	errAprobacionSinCifrado   = errors.New("las reservas fuera de la política requieren PII_KEYS para guardar los datos de los pasajeros")
+)
+
+// Guarda la reserva como pendiente de aprobacion y avisa a los aprobadores.
+// La solicitud vence cuando vence el precio cotizado. Sin PII_KEYS no se
+// guarda, para no dejar los datos de los pasajeros en claro
+func crearSolicitudAprobacion(ctx context.Context, cuerpo []byte, reserva Booking, aprobacion AprobacionPolitica, ahora time.Time) (SolicitudAprobacion, error) {
+	if clavesPII == nil {
+		return SolicitudAprobacion{}, errAprobacionSinCifrado
+	}
+	cifrado, err := clavesPII.cifrar(string(cuerpo))
+	if err != nil {
+		return SolicitudAprobacion{}, err
+	}
+
+	solicitud := SolicitudAprobacion{
+		Id:         generarId(),
+		Agencia:    idAgencia(ctx),
+		Estado:     aprobacionPendiente,
+		Creada:     ahora.UTC(),
+		Expira:     vencimientoPrecio(reserva.Data.FlightOffers, ahora).UTC(),
+		Aprobacion: aprobacion,
+		Cuerpo:     cifrado,
+	}
+	var rutas, totales []string
+	for _, oferta := range reserva.Data.FlightOffers {
+		rutas = append(rutas, rutaOferta(oferta))
+		totales = append(totales, oferta.Price.GrandTotal+" "+oferta.Price.Currency)
+	}
+	solicitud.Ruta = strings.Join(rutas, ", ")
+	solicitud.Total = strings.Join(totales, ", ")
+
+	clientDB, err := conectarMongo(ctx)
+	if err != nil {
+		return SolicitudAprobacion{}, err
+	}
+	defer clientDB.Disconnect(context.TODO())
+
+	collection := clientDB.Database(baseDeDatos).Collection(coleccionAprobacionesDe(ctx))
+	if _, err := collection.InsertOne(ctx, solicitud); err != nil {
+		return SolicitudAprobacion{}, err
+	}
+	auditarReserva(ctx, "PENDIENTE_APROBACION", solicitud.Id, 0)
+
+	enSegundoPlano(func() { avisarAprobadores(mailer, solicitud) })
+	return solicitud, nil
+}
+
+// Momento en que vence el precio de las ofertas cotizadas: el ultimo
+// momento de emision mas cercano (lastTicketingDateTime, o el fin del dia
+// de lastTicketingDate). Si ninguna oferta lo indica se usa
+// config.VigenciaPrecio
+func vencimientoPrecio(ofertas []FlightOffer, ahora time.Time) time.Time {
+	var vence time.Time
+	for _, oferta := range ofertas {
+		limite, ok := ultimaEmision(oferta)
+		if ok && (vence.IsZero() || limite.Before(vence)) {
+			vence = limite
+		}
+	}
+	if vence.IsZero() {
+		return ahora.Add(config.VigenciaPrecio)
+	}
+	return vence
+}
+
+// Ultimo momento de emision de una oferta. Las fechas del proveedor no
+// traen zona horaria y se toman en UTC
+func ultimaEmision(oferta FlightOffer) (time.Time, bool) {
+	if oferta.LastTicketingDateTime != "" {
+		for _, formato := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04"} {
+			if limite, err := time.Parse(formato, oferta.LastTicketingDateTime); err == nil {
+				return limite, true
+			}
+		}
+	}
+	if dia, err := time.Parse(formatoFecha, oferta.LastTicketingDate); err == nil {
+		return dia.AddDate(0, 0, 1), true
+	}
+	return time.Time{}, false
+}
+
+var plantillaAprobacion = template.Must(template.New("aprobacion").Parse(`Hay una reserva fuera de la política de viajes esperando aprobación.
+
+Solicitud: {{.Id}}
+{{if .Agencia}}Agencia: {{.Agencia}} (cabecera X-Agency)
+{{end}}Ruta: {{.Ruta}}
+Total: {{.Total}}
+Motivo: {{.Aprobacion.Motivo}}
+
+Infracciones:
+{{range .Aprobacion.Infracciones}}- {{.}}
+{{end}}
+Para aprobarla: POST /admin/approvals/{{.Id}}/approve
+Para rechazarla: POST /admin/approvals/{{.Id}}/reject
+
+La solicitud vence el {{.Expira.Format "2006-01-02 15:04 MST"}}, cuando deja de ser válido el precio cotizado.
+goTravel
+`))
+
+// Envia la solicitud a cada correo de config.Aprobadores
+func avisarAprobadores(m Mailer, solicitud SolicitudAprobacion) {
+	var cuerpo bytes.Buffer
+	if err := plantillaAprobacion.Execute(&cuerpo, solicitud); err != nil {
+		slog.Error("Error al generar el correo de aprobación", "error", err)
+		return
+	}
+
+	enviados := 0
+	for _, para := range strings.Split(config.Aprobadores, ",") {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := m.Enviar(ctx, Correo{Para: para, Asunto: "Reserva pendiente de aprobación " + solicitud.Id, Cuerpo: cuerpo.String()})
+		cancel()
+		if err != nil {
+			slog.Error("Error al avisar a un aprobador", "solicitud", solicitud.Id, "error", err)
+			continue
+		}
+		enviados++
+	}
+	if enviados == 0 {
+		slog.Warn("No se avisó a ningún aprobador (APPROVERS)", "solicitud", solicitud.Id)
+	}
+}
+
+// Estado de una solicitud, para quien hizo la reserva
+func consultarAprobacion(c *gin.Context) {
+	solicitud, err := buscarSolicitudAprobacion(c.Request.Context(), c.Param("id"))
+	if err == errAprobacionNoEncontrada {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, solicitud)
+}
+
+// Lista las solicitudes de aprobacion, por defecto las pendientes. Con
+// estado se filtra por otro estado
+func consultarAprobaciones(c *gin.Context) {
+	estado := c.DefaultQuery("estado", aprobacionPendiente)
+
+	ctx := c.Request.Context()
+	clientDB, err := conectarMongo(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al conectar con la base de datos"})
+		return
+	}
+	defer clientDB.Disconnect(context.TODO())
+
+	collection := clientDB.Database(baseDeDatos).Collection(coleccionAprobacionesDe(ctx))
+	opciones := options.Find().
+		SetProjection(bson.M{"cuerpo": 0}).
+		SetSort(bson.M{"creada": 1}).
+		SetLimit(100)
+	cursor, err := collection.Find(ctx, bson.M{"estado": estado}, opciones)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	solicitudes := []SolicitudAprobacion{}
+	if err := cursor.All(ctx, &solicitudes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, solicitudes)
+}
+
+// Aprueba una solicitud pendiente y crea la reserva en el proveedor. Si el
+// precio ya vencio responde 410
+func aprobarReserva(c *gin.Context) {
+	// La reserva continúa aunque el aprobador se desconecte
+	ctx := context.WithoutCancel(c.Request.Context())
+	id := c.Param("id")
+	ahora := time.Now()
+
+	clientDB, err := conectarMongo(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al conectar con la base de datos"})
+		return
+	}
+	defer clientDB.Disconnect(context.TODO())
+	collection := clientDB.Database(baseDeDatos).Collection(coleccionAprobacionesDe(ctx))
+
+	// Se toma la solicitud en un solo paso para que dos aprobadores no creen
+	// la reserva dos veces
+	var solicitud SolicitudAprobacion
+	filtro := bson.M{"_id": id, "estado": aprobacionPendiente, "expira": bson.M{"$gt": ahora.UTC()}}
+	err = collection.FindOneAndUpdate(ctx, filtro, bson.M{"$set": bson.M{"estado": aprobacionEnviando, "enviada": ahora.UTC()}}).Decode(&solicitud)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		responderNoPendiente(c, id)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	resolver := func(estado string, set bson.M) {
+		set["estado"] = estado
+		set["resuelta"] = time.Now().UTC()
+		actualizacion := bson.M{"$set": set, "$unset": bson.M{"cuerpo": ""}}
+		if _, err := collection.UpdateOne(ctx, bson.M{"_id": id}, actualizacion); err != nil {
+			slog.ErrorContext(ctx, "Error al actualizar la solicitud de aprobación", "solicitud", id, "error", err)
+		}
+		auditarReserva(ctx, strings.ToUpper(estado), id, 0)
+	}
+
+	cuerpo, err := clavesPII.descifrar(solicitud.Cuerpo)
+	if err != nil {
+		resolver(aprobacionFallida, bson.M{"comentario": "No se pudo descifrar la solicitud"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo descifrar la solicitud"})
+		return
+	}
+
+	// La reserva guardada apunta a la solicitud, para recuperarla si el
+	// servidor se interrumpe antes de resolverla
+	solicitud.Aprobacion.Solicitud = id
+	orden, err := crearOrden(ctx, []byte(cuerpo), &solicitud.Aprobacion)
+	if errors.Is(err, errOrdenIncierta) {
+		// La solicitud queda en enviando hasta saber si la reserva existe
+		c.JSON(http.StatusBadGateway, gin.H{"error": "No se sabe si el proveedor creó la reserva, revise sus reservas antes de reintentar"})
+		return
+	}
+	if err != nil || (orden.Reserva.Data.Id == "" && orden.Advertencia == "") {
+		detalle, estado := mensajeRechazoProveedor(orden.Cuerpo), estadoRechazoProveedor(orden.Estado)
+		if err != nil {
+			detalle, estado = err.Error(), http.StatusBadGateway
+		}
+		resolver(aprobacionFallida, bson.M{"comentario": detalle})
+		c.JSON(estado, gin.H{"error": detalle})
+		return
+	}
+
+	reserva := orden.Reserva
+	set := bson.M{"idReserva": reserva.Data.Id}
+	respuesta := gin.H{"id": id, "status": aprobacionAprobada, "bookingId": reserva.Data.Id}
+	if orden.Advertencia != "" {
+		set["comentario"] = orden.Advertencia
+		respuesta["warning"] = orden.Advertencia
+	}
+	resolver(aprobacionAprobada, set)
+	if reserva.Data.Id != "" {
+		enSegundoPlano(func() { enviarConfirmaciones(mailer, reserva) })
+	}
+	c.JSON(http.StatusOK, respuesta)
+}
+
+// Rechaza una solicitud pendiente. El cuerpo puede incluir un comentario
+func rechazarReserva(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+
+	var datos struct {
+		Comentario string `json:"comment"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&datos); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	clientDB, err := conectarMongo(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al conectar con la base de datos"})
+		return
+	}
+	defer clientDB.Disconnect(context.TODO())
+	collection := clientDB.Database(baseDeDatos).Collection(coleccionAprobacionesDe(ctx))
+
+	actualizacion := bson.M{
+		"$set":   bson.M{"estado": aprobacionRechazada, "resuelta": time.Now().UTC(), "comentario": datos.Comentario},
+		"$unset": bson.M{"cuerpo": ""},
+	}
+	resultado, err := collection.UpdateOne(ctx, bson.M{"_id": id, "estado": aprobacionPendiente}, actualizacion)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if resultado.MatchedCount == 0 {
+		responderNoPendiente(c, id)
+		return
+	}
+	auditarReserva(ctx, "RECHAZADA", id, 0)
+	c.JSON(http.StatusOK, gin.H{"id": id, "status": aprobacionRechazada})
+}
+
+// Responde por que una solicitud no se puede aprobar ni rechazar: no existe,
+// ya vencio o ya fue resuelta
+func responderNoPendiente(c *gin.Context, id string) {
+	solicitud, err := buscarSolicitudAprobacion(c.Request.Context(), id)
+	switch {
+	case err == errAprobacionNoEncontrada:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	case solicitud.Estado == aprobacionVencida || (solicitud.Estado == aprobacionPendiente && !time.Now().Before(solicitud.Expira)):
+		c.JSON(http.StatusGone, gin.H{"error": "La solicitud venció porque el precio cotizado ya no es válido", "status": aprobacionVencida})
+	default:
+		c.JSON(http.StatusConflict, gin.H{"error": "La solicitud ya fue resuelta", "status": solicitud.Estado})
+	}
+}
+
+func buscarSolicitudAprobacion(ctx context.Context, id string) (SolicitudAprobacion, error) {
+	clientDB, err := conectarMongo(ctx)
+	if err != nil {
+		return SolicitudAprobacion{}, err
+	}
+	defer clientDB.Disconnect(context.TODO())
+
+	collection := clientDB.Database(baseDeDatos).Collection(coleccionAprobacionesDe(ctx))
+	var solicitud SolicitudAprobacion
+	err = collection.FindOne(ctx, bson.M{"_id": id}, options.FindOne().SetProjection(bson.M{"cuerpo": 0})).Decode(&solicitud)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return SolicitudAprobacion{}, errAprobacionNoEncontrada
+	}
+	return solicitud, err
+}
+
+// Vence las solicitudes pendientes y recupera las que quedaron en enviando
+// al iniciar y luego cada intervaloAprobaciones, hasta que se cancele ctx
+func iniciarVencimientoAprobaciones(ctx context.Context) {
+	enSegundoPlano(func() {
+		ticker := time.NewTicker(intervaloAprobaciones)
+		defer ticker.Stop()
+		for {
+			if n, err := paraCadaAgencia(ctx, func(ctx context.Context) (int, error) { return vencerAprobaciones(ctx, time.Now()) }); err != nil {
+				slog.ErrorContext(ctx, "Error al vencer las solicitudes de aprobación", "error", err)
+			} else if n > 0 {
+				slog.InfoContext(ctx, "Solicitudes de aprobación vencidas", "cantidad", n)
+			}
+			if n, err := paraCadaAgencia(ctx, func(ctx context.Context) (int, error) { return recuperarAprobacionesEnviando(ctx, time.Now()) }); err != nil {
+				slog.ErrorContext(ctx, "Error al recuperar las solicitudes de aprobación interrumpidas", "error", err)
+			} else if n > 0 {
+				slog.WarnContext(ctx, "Solicitudes de aprobación interrumpidas al crear la reserva", "cantidad", n)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+// Marca como vencidas las solicitudes pendientes cuyo precio ya no es
+// valido y elimina los datos de sus pasajeros. Devuelve la cantidad vencida
+func vencerAprobaciones(ctx context.Context, ahora time.Time) (int, error) {
+	clientDB, err := conectarMongo(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer clientDB.Disconnect(context.TODO())
+
+	collection := clientDB.Database(baseDeDatos).Collection(coleccionAprobacionesDe(ctx))
+	filtro := bson.M{"estado": aprobacionPendiente, "expira": bson.M{"$lte": ahora.UTC()}}
+	cursor, err := collection.Find(ctx, filtro, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	vencidas := 0
+	for cursor.Next(ctx) {
+		var documento struct {
+			Id string `bson:"_id"`
+		}
+		if err := cursor.Decode(&documento); err != nil {
+			return vencidas, err
+		}
+
+		// Se repite el estado en el filtro por si se aprobo mientras tanto
+		actualizacion := bson.M{
+			"$set":   bson.M{"estado": aprobacionVencida, "resuelta": ahora.UTC()},
+			"$unset": bson.M{"cuerpo": ""},
+		}
+		resultado, err := collection.UpdateOne(ctx, bson.M{"_id": documento.Id, "estado": aprobacionPendiente}, actualizacion)
+		if err != nil {
+			return vencidas, err
+		}
+		if resultado.ModifiedCount > 0 {
+			auditarReserva(ctx, "VENCIDA", documento.Id, 0)
+			vencidas++
+		}
+	}
+	return vencidas, cursor.Err()
+}
+
+// Resuelve las solicitudes que llevan mas de plazoEnvioAprobacion en
+// enviando. Si se guardo una reserva para la solicitud queda aprobada con
+// ese id; si no, queda incierta para revisarla en el proveedor. En ambos
+// casos se eliminan los datos de los pasajeros. Devuelve la cantidad
+// resuelta
+func recuperarAprobacionesEnviando(ctx context.Context, ahora time.Time) (int, error) {
+	clientDB, err := conectarMongo(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer clientDB.Disconnect(context.TODO())
+
+	collection := clientDB.Database(baseDeDatos).Collection(coleccionAprobacionesDe(ctx))
+	reservas := clientDB.Database(baseDeDatos).Collection(coleccionReservasDe(ctx))
+	filtro := bson.M{"estado": aprobacionEnviando, "enviada": bson.M{"$lte": ahora.Add(-plazoEnvioAprobacion).UTC()}}
+	cursor, err := collection.Find(ctx, filtro, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	recuperadas := 0
+	for cursor.Next(ctx) {
+		var documento struct {
+			Id string `bson:"_id"`
+		}
+		if err := cursor.Decode(&documento); err != nil {
+			return recuperadas, err
+		}
+
+		set := bson.M{"estado": aprobacionIncierta, "resuelta": ahora.UTC(), "comentario": "La reserva se interrumpió; revise en el proveedor si se creó"}
+		var reserva ReservaGuardada
+		err := reservas.FindOne(ctx, bson.M{"politica.solicitud": documento.Id}).Decode(&reserva)
+		switch {
+		case err == nil:
+			set = bson.M{"estado": aprobacionAprobada, "resuelta": ahora.UTC(), "idReserva": reserva.Data.Id}
+		case !errors.Is(err, mongo.ErrNoDocuments):
+			return recuperadas, err
+		}
+
+		// Se repite el estado en el filtro por si se resolvio mientras tanto
+		resultado, err := collection.UpdateOne(ctx, bson.M{"_id": documento.Id, "estado": aprobacionEnviando}, bson.M{"$set": set, "$unset": bson.M{"cuerpo": ""}})
+		if err != nil {
+			return recuperadas, err
+		}
+		if resultado.ModifiedCount > 0 {
+			auditarReserva(ctx, strings.ToUpper(set["estado"].(string)), documento.Id, 0)
+			recuperadas++
+		}
+	}
+	return recuperadas, cursor.Err()
+}
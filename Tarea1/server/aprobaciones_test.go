@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVencimientoPrecioUsaElUltimoDiaDeEmision(t *testing.T) {
+	anterior := config
+	t.Cleanup(func() { config = anterior })
+	config = configuracionPorDefecto()
+
+	ahora := time.Date(2030, 3, 1, 12, 0, 0, 0, time.UTC)
+	conFecha, conHora := ofertaPrueba("1", "IB6830", "812.34"), ofertaPrueba("2", "LA704", "905.10")
+	conFecha.LastTicketingDate = "2030-03-05"
+	conHora.LastTicketingDate = "2030-03-04"
+	conHora.LastTicketingDateTime = "2030-03-04T18:30:00"
+
+	casos := []struct {
+		nombre  string
+		ofertas []FlightOffer
+		vence   time.Time
+	}{
+		{"fin del dia", []FlightOffer{conFecha}, time.Date(2030, 3, 6, 0, 0, 0, 0, time.UTC)},
+		{"la mas cercana", []FlightOffer{conFecha, conHora}, time.Date(2030, 3, 4, 18, 30, 0, 0, time.UTC)},
+		{"sin datos", []FlightOffer{ofertaPrueba("3", "UX46", "700.00")}, ahora.Add(config.VigenciaPrecio)},
+	}
+	for _, caso := range casos {
+		if vence := vencimientoPrecio(caso.ofertas, ahora); !vence.Equal(caso.vence) {
+			t.Errorf("%s: vence = %v, se esperaba %v", caso.nombre, vence, caso.vence)
+		}
+	}
+}
+
+func TestSolicitudAprobacionRequiereClavesPII(t *testing.T) {
+	anteriores := clavesPII
+	t.Cleanup(func() { clavesPII = anteriores })
+	clavesPII = nil
+
+	_, err := crearSolicitudAprobacion(context.Background(), []byte(`{}`), Booking{}, AprobacionPolitica{}, time.Now())
+	if !errors.Is(err, errAprobacionSinCifrado) {
+		t.Errorf("err = %v, se esperaba errAprobacionSinCifrado", err)
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Mapa de asientos de un segmento, con el formato de seatmaps de Amadeus
+type SeatMap struct {
+	Type          string    `json:"type"`
+	FlightOfferId string    `json:"flightOfferId"`
+	SegmentId     string    `json:"segmentId"`
+	CarrierCode   string    `json:"carrierCode"`
+	Number        string    `json:"number"`
+	Aircraft      Aircraft  `json:"aircraft"`
+	Departure     Departure `json:"departure"`
+	Arrival       Arrival   `json:"arrival"`
+	Decks         []Deck    `json:"decks"`
+
+	// Mapa generado por modoAsientosSimulados. Sus asientos no existen en
+	// el proveedor y no se pueden reservar
+	Simulado bool `json:"simulated,omitempty"`
+}
+
+type Deck struct {
+	DeckType string `json:"deckType"`
+	Seats    []Seat `json:"seats"`
+}
+
+type Seat struct {
+	Cabin                string             `json:"cabin"`
+	Number               string             `json:"number"`
+	CharacteristicsCodes []string           `json:"characteristicsCodes,omitempty"`
+	TravelerPricing      []SeatAvailability `json:"travelerPricing"`
+	Coordinates          struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+	} `json:"coordinates"`
+}
+
+// Disponibilidad y precio de un asiento para un pasajero
+type SeatAvailability struct {
+	TravelerId             string     `json:"travelerId"`
+	SeatAvailabilityStatus string     `json:"seatAvailabilityStatus"`
+	Price                  *SeatPrice `json:"price,omitempty"`
+}
+
+type SeatPrice struct {
+	Currency string `json:"currency"`
+	Total    string `json:"total"`
+}
+
+// Modo del proveedor que genera los mapas de asientos sin consultar a
+// Amadeus. Solo los mapas se simulan: la busqueda, el pricing y las
+// reservas siguen yendo al proveedor
+const modoAsientosSimulados = "fake-seatmaps"
+
+// Parte del precio de cada pasajero que cuesta un asiento con cargo en la
+// cabina simulada
+const recargoAsientoSimulado = 0.05
+
+// Devuelve los mapas de asientos de ofertas ya cotizadas. Recibe el mismo
+// cuerpo que /pricing
+func mapaAsientosOferta(c *gin.Context) {
+	var solicitud FlightOffersPricing
+	if err := c.ShouldBindJSON(&solicitud); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(solicitud.Data.FlightOffers) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Debe indicar al menos una oferta"})
+		return
+	}
+
+	if config.ModoProveedor == modoAsientosSimulados {
+		c.JSON(http.StatusOK, mapasSimulados(solicitud.Data.FlightOffers, monedaAgencia(c.Request.Context())))
+		return
+	}
+
+	if err := quitarAnotaciones(solicitud.Data.FlightOffers); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	cuerpo, err := json.Marshal(gin.H{"data": solicitud.Data.FlightOffers})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	responderMapasProveedor(c, "POST", config.URLProveedor+"/v1/shopping/seatmaps", cuerpo)
+}
+
+// Devuelve los mapas de asientos de una reserva existente
+func mapaAsientosReserva(c *gin.Context) {
+	id := c.Param("id")
+
+	if config.ModoProveedor == modoAsientosSimulados {
+		reserva, err := buscarReservaGuardada(c.Request.Context(), id)
+		if errors.Is(err, errReservaNoEncontrada) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, mapasSimulados(reserva.Data.FlightOffers, monedaAgencia(c.Request.Context())))
+		return
+	}
+
+	responderMapasProveedor(c, "GET", config.URLProveedor+"/v1/shopping/seatmaps?flight-orderId="+url.QueryEscape(id), nil)
+}
+
+func responderMapasProveedor(c *gin.Context, metodo, apiUrl string, cuerpo []byte) {
+	mapas, estado, err := consultarMapas(c.Request.Context(), metodo, apiUrl, cuerpo)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error al obtener los mapas de asientos", "error", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Error al obtener los mapas de asientos"})
+		return
+	}
+	if estado >= 400 {
+		c.JSON(estado, gin.H{"error": "El proveedor no entregó los mapas de asientos"})
+		return
+	}
+	c.JSON(http.StatusOK, mapas)
+}
+
+func consultarMapas(ctx context.Context, metodo, apiUrl string, cuerpo []byte) ([]SeatMap, int, error) {
+	token, err := obtenerToken(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error al obtener el token: %w", err)
+	}
+
+	var datos io.Reader
+	if cuerpo != nil {
+		datos = bytes.NewBuffer(cuerpo)
+	}
+	req, err := http.NewRequestWithContext(ctx, metodo, apiUrl, datos)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := clienteAmadeus.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, resp.StatusCode, nil
+	}
+
+	var respuesta struct {
+		Data []SeatMap `json:"data"`
+	}
+	if err := json.Unmarshal(body, &respuesta); err != nil {
+		return nil, 0, err
+	}
+	return respuesta.Data, resp.StatusCode, nil
+}
+
+// Columnas y filas de salida de la cabina simulada. El pasillo va entre C
+// y D
+var (
+	columnasSimuladas = []string{"A", "B", "C", "D", "E", "F"}
+	filasSalida       = map[int]bool{12: true, 13: true}
+)
+
+const filasSimuladas = 30
+
+// Moneda de la agencia de la solicitud
+func monedaAgencia(ctx context.Context) string {
+	if agencia := agenciaDe(ctx); agencia != nil && agencia.Moneda != "" {
+		return agencia.Moneda
+	}
+	return monedaPorDefecto
+}
+
+// Genera una cabina por segmento a partir del vuelo y la fecha, de modo que
+// el mismo vuelo siempre tenga los mismos asientos ocupados. Los asientos
+// con cargo cuestan una parte del precio de cada pasajero, en la moneda de
+// la oferta (o en la de la agencia si la oferta no la indica)
+func mapasSimulados(ofertas []FlightOffer, moneda string) []SeatMap {
+	mapas := []SeatMap{}
+	for _, oferta := range ofertas {
+		monedaOferta := oferta.Price.Currency
+		if monedaOferta == "" {
+			monedaOferta = moneda
+		}
+
+		var pasajeros []string
+		precios := make(map[string]*SeatPrice)
+		for _, pricing := range oferta.TravelerPricings {
+			// Los infantes en brazos no ocupan asiento
+			if pricing.TravelerType == "HELD_INFANT" {
+				continue
+			}
+			pasajeros = append(pasajeros, pricing.TravelerId)
+			if total, err := strconv.ParseFloat(pricing.Price.Total, 64); err == nil && total > 0 {
+				precios[pricing.TravelerId] = &SeatPrice{Currency: monedaOferta, Total: strconv.FormatFloat(total*recargoAsientoSimulado, 'f', 2, 64)}
+			}
+		}
+
+		for _, itinerario := range oferta.Itineraries {
+			for _, segmento := range itinerario.Segments {
+				mapa := SeatMap{
+					Type:          "seatmap",
+					FlightOfferId: oferta.Id,
+					SegmentId:     segmento.Id,
+					CarrierCode:   segmento.CarrierCode,
+					Number:        segmento.Number,
+					Aircraft:      segmento.Aircraft,
+					Departure:     segmento.Departure,
+					Arrival:       segmento.Arrival,
+					Simulado:      true,
+				}
+				semilla := segmento.CarrierCode + segmento.Number + segmento.Departure.At
+
+				cubierta := Deck{DeckType: "MAIN"}
+				for fila := 1; fila <= filasSimuladas; fila++ {
+					for i, columna := range columnasSimuladas {
+						asiento := Seat{Cabin: "ECONOMY", Number: strconv.Itoa(fila) + columna}
+						asiento.Coordinates.X = fila
+						asiento.Coordinates.Y = i
+						if i >= 3 {
+							asiento.Coordinates.Y = i + 1
+						}
+						switch columna {
+						case "A", "F":
+							asiento.CharacteristicsCodes = append(asiento.CharacteristicsCodes, "W")
+						case "C", "D":
+							asiento.CharacteristicsCodes = append(asiento.CharacteristicsCodes, "A")
+						}
+						if filasSalida[fila] {
+							asiento.CharacteristicsCodes = append(asiento.CharacteristicsCodes, "E")
+						}
+
+						estado := "AVAILABLE"
+						if valorSimulado(semilla, asiento.Number)%3 == 0 {
+							estado = "OCCUPIED"
+						}
+						// Las primeras filas y las de salida tienen costo
+						conCosto := fila <= 4 || filasSalida[fila]
+						for _, pasajero := range pasajeros {
+							disponibilidad := SeatAvailability{TravelerId: pasajero, SeatAvailabilityStatus: estado}
+							if conCosto {
+								disponibilidad.Price = precios[pasajero]
+							}
+							asiento.TravelerPricing = append(asiento.TravelerPricing, disponibilidad)
+						}
+						cubierta.Seats = append(cubierta.Seats, asiento)
+					}
+				}
+				mapa.Decks = append(mapa.Decks, cubierta)
+				mapas = append(mapas, mapa)
+			}
+		}
+	}
+	return mapas
+}
+
+func valorSimulado(partes ...string) uint32 {
+	h := fnv.New32a()
+	for _, parte := range partes {
+		h.Write([]byte(parte))
+		h.Write([]byte{0})
+	}
+	return h.Sum32()
+}
@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestMapasSimuladosCobranParteDelPrecio(t *testing.T) {
+	oferta := ofertaPrueba("1", "IB6830", "812.34")
+	oferta.Price.Currency = ""
+	oferta.TravelerPricings = make([]TravelerPricing, 2)
+	oferta.TravelerPricings[0].TravelerId = "1"
+	oferta.TravelerPricings[0].Price.Total = "400.00"
+	oferta.TravelerPricings[1].TravelerId = "2"
+	oferta.TravelerPricings[1].TravelerType = "HELD_INFANT"
+	oferta.TravelerPricings[1].Price.Total = "40.00"
+
+	mapas := mapasSimulados([]FlightOffer{oferta}, "USD")
+	if len(mapas) != 1 {
+		t.Fatalf("mapas = %d, se esperaba uno por segmento", len(mapas))
+	}
+	if !mapas[0].Simulado {
+		t.Error("el mapa no está marcado como simulado")
+	}
+	for _, asiento := range mapas[0].Decks[0].Seats {
+		if len(asiento.TravelerPricing) != 1 {
+			t.Fatalf("asiento %s con %d pasajeros, el infante no ocupa asiento", asiento.Number, len(asiento.TravelerPricing))
+		}
+		precio := asiento.TravelerPricing[0].Price
+		switch asiento.Number {
+		case "1A":
+			if precio == nil || precio.Total != "20.00" || precio.Currency != "USD" {
+				t.Errorf("precio del 1A = %+v, se esperaban 20.00 USD", precio)
+			}
+		case "20C":
+			if precio != nil {
+				t.Errorf("el 20C no deberia tener costo: %+v", precio)
+			}
+		}
+	}
+}
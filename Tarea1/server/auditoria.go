@@ -0,0 +1,492 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const coleccionAuditoria = "auditoria"
+
+// Tipos de registro de auditoria
+const (
+	auditoriaProveedor = "proveedor"
+	auditoriaReserva   = "reserva"
+)
+
+// Registro de la coleccion de auditoria. La coleccion es de solo escritura:
+// el servidor nunca actualiza ni elimina registros
+type RegistroAuditoria struct {
+	Tipo            string    `bson:"tipo" json:"tipo"`
+	Fecha           time.Time `bson:"fecha" json:"fecha"`
+	IdSolicitud     string    `bson:"idSolicitud,omitempty" json:"idSolicitud,omitempty"`
+	Agencia         string    `bson:"agencia,omitempty" json:"agencia,omitempty"`
+	Metodo          string    `bson:"metodo,omitempty" json:"metodo,omitempty"`
+	Endpoint        string    `bson:"endpoint,omitempty" json:"endpoint,omitempty"`
+	Solicitud       string    `bson:"solicitud,omitempty" json:"solicitud,omitempty"`
+	Estado          int       `bson:"estado" json:"estado"`
+	LatenciaMs      int64     `bson:"latenciaMs" json:"latenciaMs"`
+	TamanoRespuesta int64     `bson:"tamanoRespuesta" json:"tamanoRespuesta"`
+	Error           string    `bson:"error,omitempty" json:"error,omitempty"`
+	Accion          string    `bson:"accion,omitempty" json:"accion,omitempty"`
+	IdReserva       string    `bson:"idReserva,omitempty" json:"idReserva,omitempty"`
+}
+
+type claveContexto string
+
+const claveIdSolicitud claveContexto = "idSolicitud"
+
+// Cabecera con la que el cliente puede enviar su propio id de solicitud
+const cabeceraIdSolicitud = "X-Request-ID"
+
+// Los registros se escriben en segundo plano para no retrasar las
+// solicitudes. Si MongoDB no responde se guardan en memoria y se reintenta;
+// un registro solo se descarta si se llena la cola o el respaldo, y eso se
+// informa como error
+var (
+	colaAuditoria      = make(chan RegistroAuditoria, 1000)
+	auditoriaCerrada   bool
+	muAuditoria        sync.RWMutex
+	auditoriaTerminada = make(chan struct{})
+
+	// Registros aun no guardados, para informarlos al apagar
+	auditoriaPendientes atomic.Int64
+)
+
+// Maximo de registros en espera mientras MongoDB no responde
+const maximoPendientesAuditoria = 10000
+
+// Espera entre reintentos de escritura de la auditoria
+const (
+	esperaMinimaAuditoria = time.Second
+	esperaMaximaAuditoria = 30 * time.Second
+)
+
+// Ids de solicitud aceptados desde el cliente. Los demas se reemplazan por
+// uno nuevo para no llevar texto arbitrario a los registros
+var patronIdSolicitud = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// Middleware que asigna un id a cada solicitud del cliente, lo guarda en el
+// contexto y lo devuelve en la respuesta. El mismo id se envia al proveedor
+// y aparece en los registros y la auditoria
+func idSolicitud() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(cabeceraIdSolicitud)
+		if !patronIdSolicitud.MatchString(id) {
+			id = generarId()
+		}
+		c.Header(cabeceraIdSolicitud, id)
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), claveIdSolicitud, id))
+		c.Next()
+	}
+}
+
+func generarId() string {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(bytes)
+}
+
+func idDeSolicitud(ctx context.Context) string {
+	id, _ := ctx.Value(claveIdSolicitud).(string)
+	return id
+}
+
+// Encola un registro de auditoria
+func registrarAuditoria(registro RegistroAuditoria) {
+	if registro.Fecha.IsZero() {
+		registro.Fecha = time.Now().UTC()
+	}
+
+	muAuditoria.RLock()
+	defer muAuditoria.RUnlock()
+	if auditoriaCerrada {
+		slog.Error("Auditoría detenida, registro descartado", "tipo", registro.Tipo, "endpoint", registro.Endpoint, "accion", registro.Accion)
+		return
+	}
+
+	select {
+	case colaAuditoria <- registro:
+		auditoriaPendientes.Add(1)
+	default:
+		slog.Error("Cola de auditoría llena, registro descartado", "tipo", registro.Tipo, "endpoint", registro.Endpoint, "accion", registro.Accion)
+	}
+}
+
+// Registra la creacion o cancelacion de una reserva
+func auditarReserva(ctx context.Context, accion string, idReserva string, estado int) {
+	registrarAuditoria(RegistroAuditoria{
+		Tipo:        auditoriaReserva,
+		IdSolicitud: idDeSolicitud(ctx),
+		Agencia:     idAgencia(ctx),
+		Accion:      accion,
+		IdReserva:   idReserva,
+		Estado:      estado,
+	})
+}
+
+// Escribe los registros encolados en MongoDB. Mantiene una sola conexion y
+// la vuelve a abrir si se pierde. Los registros que no se pueden guardar se
+// reintentan, en orden, con una espera creciente
+func iniciarAuditoria() {
+	go func() {
+		defer close(auditoriaTerminada)
+
+		var clientDB *mongo.Client
+		defer func() {
+			if clientDB != nil {
+				clientDB.Disconnect(context.TODO())
+			}
+		}()
+
+		// Guarda los pendientes uno a uno, para no repetir los ya guardados
+		// si falla a mitad de camino
+		guardar := func(pendientes []RegistroAuditoria) ([]RegistroAuditoria, error) {
+			if clientDB == nil {
+				var err error
+				if clientDB, err = conectarMongo(context.TODO()); err != nil {
+					clientDB = nil
+					return pendientes, err
+				}
+			}
+			collection := clientDB.Database(baseDeDatos).Collection(coleccionAuditoria)
+			for len(pendientes) > 0 {
+				if _, err := collection.InsertOne(context.TODO(), pendientes[0]); err != nil {
+					clientDB.Disconnect(context.TODO())
+					clientDB = nil
+					return pendientes, err
+				}
+				pendientes = pendientes[1:]
+				auditoriaPendientes.Add(-1)
+			}
+			return nil, nil
+		}
+
+		var pendientes []RegistroAuditoria
+		agregar := func(registro RegistroAuditoria) {
+			if len(pendientes) >= maximoPendientesAuditoria {
+				auditoriaPendientes.Add(-1)
+				slog.Error("Respaldo de auditoría lleno, registro descartado", "tipo", registro.Tipo, "endpoint", registro.Endpoint, "accion", registro.Accion)
+				return
+			}
+			pendientes = append(pendientes, registro)
+		}
+
+		cola := colaAuditoria
+		espera := esperaMinimaAuditoria
+		for {
+			if len(pendientes) == 0 {
+				if cola == nil {
+					return
+				}
+				registro, ok := <-cola
+				if !ok {
+					return
+				}
+				agregar(registro)
+			}
+
+			var err error
+			if pendientes, err = guardar(pendientes); err == nil {
+				espera = esperaMinimaAuditoria
+				continue
+			}
+			slog.Error("Error al guardar la auditoría, se reintentará", "pendientes", len(pendientes), "espera", espera.String(), "error", err)
+
+			// Mientras se espera se siguen recibiendo registros. Al cerrar la
+			// cola se sigue reintentando hasta que se cumpla el plazo de
+			// apagado
+			reintento := time.After(espera)
+			espera = min(espera*2, esperaMaximaAuditoria)
+		esperar:
+			for {
+				select {
+				case registro, ok := <-cola:
+					if !ok {
+						cola = nil
+						continue
+					}
+					agregar(registro)
+				case <-reintento:
+					break esperar
+				}
+			}
+		}
+	}()
+}
+
+// Cierra la cola de auditoría y espera a que se escriban los registros
+// pendientes o a que se cumpla el plazo del contexto
+func detenerAuditoria(ctx context.Context) {
+	muAuditoria.Lock()
+	if !auditoriaCerrada {
+		auditoriaCerrada = true
+		close(colaAuditoria)
+	}
+	muAuditoria.Unlock()
+
+	select {
+	case <-auditoriaTerminada:
+	case <-ctx.Done():
+		slog.Error("Se cumplió el plazo de apagado con registros de auditoría sin guardar", "pendientes", auditoriaPendientes.Load())
+	}
+}
+
+// Transporte HTTP que audita cada llamada al proveedor
+type transporteAuditado struct {
+	base http.RoundTripper
+}
+
+func (t transporteAuditado) RoundTrip(req *http.Request) (*http.Response, error) {
+	registro := RegistroAuditoria{
+		Tipo:        auditoriaProveedor,
+		IdSolicitud: idDeSolicitud(req.Context()),
+		Agencia:     idAgencia(req.Context()),
+		Metodo:      req.Method,
+		Endpoint:    req.URL.Scheme + "://" + req.URL.Host + req.URL.Path,
+	}
+
+	if req.Body != nil && req.GetBody != nil {
+		if copia, err := req.GetBody(); err == nil {
+			cuerpo, _ := io.ReadAll(copia)
+			copia.Close()
+			registro.Solicitud = sanitizarSolicitud(req.Header.Get("Content-Type"), cuerpo)
+		}
+	}
+	if req.URL.RawQuery != "" {
+		registro.Endpoint += "?" + req.URL.RawQuery
+	}
+
+	inicio := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		registro.LatenciaMs = time.Since(inicio).Milliseconds()
+		registro.Error = err.Error()
+		registrarAuditoria(registro)
+		return nil, err
+	}
+
+	registro.Estado = resp.StatusCode
+	resp.Body = &cuerpoAuditado{ReadCloser: resp.Body, registro: registro, inicio: inicio}
+	return resp, nil
+}
+
+// Cuenta los bytes de la respuesta y registra la llamada al cerrarla
+type cuerpoAuditado struct {
+	io.ReadCloser
+	registro RegistroAuditoria
+	inicio   time.Time
+	leidos   int64
+	cerrar   sync.Once
+}
+
+func (c *cuerpoAuditado) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.leidos += int64(n)
+	return n, err
+}
+
+func (c *cuerpoAuditado) Close() error {
+	err := c.ReadCloser.Close()
+	c.cerrar.Do(func() {
+		c.registro.LatenciaMs = time.Since(c.inicio).Milliseconds()
+		c.registro.TamanoRespuesta = c.leidos
+		registrarAuditoria(c.registro)
+	})
+	return err
+}
+
+const textoRedactado = "[REDACTADO]"
+
+// Campos con datos personales o secretos que nunca se guardan
+var camposSensibles = map[string]bool{
+	"firstName":     true,
+	"lastName":      true,
+	"dateOfBirth":   true,
+	"gender":        true,
+	"emailAddress":  true,
+	"documents":     true,
+	"client_id":     true,
+	"client_secret": true,
+}
+
+// Campos que solo son sensibles dentro de ciertos objetos, por ejemplo el
+// numero de telefono (el numero de vuelo no lo es)
+var camposSensiblesEn = map[string]map[string]bool{
+	"phones": {"number": true, "countryCallingCode": true},
+}
+
+// Elimina los datos personales del cuerpo de una solicitud
+func sanitizarSolicitud(tipo string, cuerpo []byte) string {
+	if len(cuerpo) == 0 {
+		return ""
+	}
+
+	if strings.HasPrefix(tipo, "application/x-www-form-urlencoded") {
+		valores, err := url.ParseQuery(string(cuerpo))
+		if err != nil {
+			return textoRedactado
+		}
+		for clave := range valores {
+			if camposSensibles[clave] {
+				valores.Set(clave, textoRedactado)
+			}
+		}
+		return valores.Encode()
+	}
+
+	var datos interface{}
+	if err := json.Unmarshal(cuerpo, &datos); err != nil {
+		return textoRedactado
+	}
+	redactado, err := json.Marshal(redactar(datos, ""))
+	if err != nil {
+		return textoRedactado
+	}
+	return string(redactado)
+}
+
+// Recorre un JSON generico reemplazando los campos sensibles. Se conserva
+// la forma del JSON (un arreglo de documentos sigue siendo un arreglo) para
+// que el resultado se pueda volver a leer, por ejemplo desde una fixture
+func redactar(valor interface{}, padre string) interface{} {
+	switch v := valor.(type) {
+	case map[string]interface{}:
+		for clave, hijo := range v {
+			if camposSensibles[clave] || camposSensiblesEn[padre][clave] {
+				v[clave] = ocultarTextos(hijo)
+				continue
+			}
+			v[clave] = redactar(hijo, clave)
+		}
+		return v
+	case []interface{}:
+		for i, hijo := range v {
+			// Los elementos de un arreglo heredan el nombre del arreglo
+			v[i] = redactar(hijo, padre)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// Reemplaza cada texto de un valor JSON por textoRedactado
+func ocultarTextos(valor interface{}) interface{} {
+	switch v := valor.(type) {
+	case string:
+		return textoRedactado
+	case map[string]interface{}:
+		for clave, hijo := range v {
+			v[clave] = ocultarTextos(hijo)
+		}
+	case []interface{}:
+		for i, hijo := range v {
+			v[i] = ocultarTextos(hijo)
+		}
+	}
+	return valor
+}
+
+// Middleware que solo deja pasar a los administradores. El token se
+// configura con ADMIN_TOKEN o adminToken; si no existe, las rutas quedan
+// deshabilitadas
+func soloAdministradores() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		esperado := config.TokenAdministrador
+		recibido := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if esperado == "" || subtle.ConstantTimeCompare([]byte(esperado), []byte(recibido)) != 1 {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Acceso no autorizado"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Consulta los registros de auditoria. Filtros opcionales: tipo,
+// idSolicitud, idReserva, desde y hasta (RFC 3339) y limite
+func consultarAuditoria(c *gin.Context) {
+	filtro := bson.M{}
+	for _, campo := range []string{"tipo", "idSolicitud", "idReserva", "accion"} {
+		if valor := c.Query(campo); valor != "" {
+			filtro[campo] = valor
+		}
+	}
+	// Cada agencia solo ve sus propios registros
+	if agencia := idAgencia(c.Request.Context()); agencia != "" {
+		filtro["agencia"] = agencia
+	}
+
+	rango := bson.M{}
+	if desde := c.Query("desde"); desde != "" {
+		fecha, err := time.Parse(time.RFC3339, desde)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Fecha desde no válida"})
+			return
+		}
+		rango["$gte"] = fecha
+	}
+	if hasta := c.Query("hasta"); hasta != "" {
+		fecha, err := time.Parse(time.RFC3339, hasta)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Fecha hasta no válida"})
+			return
+		}
+		rango["$lte"] = fecha
+	}
+	if len(rango) > 0 {
+		filtro["fecha"] = rango
+	}
+
+	limite := int64(100)
+	if valor := c.Query("limite"); valor != "" {
+		n, err := strconv.ParseInt(valor, 10, 64)
+		if err != nil || n <= 0 || n > 1000 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Límite no válido (1 a 1000)"})
+			return
+		}
+		limite = n
+	}
+
+	clientDB, err := conectarMongo(c.Request.Context())
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error al conectar con MongoDB", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al conectar con la base de datos"})
+		return
+	}
+	defer clientDB.Disconnect(context.TODO())
+
+	opciones := options.Find().SetSort(bson.D{{Key: "fecha", Value: -1}}).SetLimit(limite)
+	cursor, err := clientDB.Database(baseDeDatos).Collection(coleccionAuditoria).Find(c.Request.Context(), filtro, opciones)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	registros := []RegistroAuditoria{}
+	if err := cursor.All(c.Request.Context(), &registros); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, registros)
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Parametros de una busqueda de vuelos. FechaRegreso, Ninos, Infantes y
+// Mayores son opcionales. Los infantes viajan en brazos de un adulto o mayor.
+// Moneda la define la agencia al consultar
+type ParametrosBusqueda struct {
+	Origen       string
+	Destino      string
+	FechaSalida  string
+	FechaRegreso string
+	Adultos      string
+	Ninos        string
+	Infantes     string
+	Mayores      string
+	Moneda       string
+}
+
+// Cantidad maxima de asientos por busqueda que admite Amadeus
+const maximoPasajeros = 9
+
+// Valida las cantidades de pasajeros de la busqueda
+func (p ParametrosBusqueda) validar() error {
+	cantidades := make(map[string]int)
+	for nombre, valor := range map[string]string{
+		"adults":   p.Adultos,
+		"children": p.Ninos,
+		"infants":  p.Infantes,
+		"seniors":  p.Mayores,
+	} {
+		if valor == "" {
+			continue
+		}
+		n, err := strconv.Atoi(valor)
+		if err != nil || n < 0 {
+			return fmt.Errorf("%s debe ser un número mayor o igual a 0", nombre)
+		}
+		cantidades[nombre] = n
+	}
+
+	adultos := cantidades["adults"] + cantidades["seniors"]
+	if adultos == 0 {
+		return fmt.Errorf("debe viajar al menos un adulto o mayor")
+	}
+	if adultos+cantidades["children"] > maximoPasajeros {
+		return fmt.Errorf("se pueden buscar como máximo %d asientos", maximoPasajeros)
+	}
+	if cantidades["infants"] > adultos {
+		return fmt.Errorf("cada infante debe viajar en brazos de un adulto o mayor")
+	}
+	return nil
+}
+
+func (p ParametrosBusqueda) moneda() string {
+	if p.Moneda == "" {
+		return monedaPorDefecto
+	}
+	return p.Moneda
+}
+
+func cantidad(valor string) int {
+	n, _ := strconv.Atoi(valor)
+	return n
+}
+
+// Tiempo que se reutiliza el resultado de una busqueda identica
+const vigenciaCacheBusqueda = 5 * time.Minute
+
+type entradaCache struct {
+	ofertas []FlightOffer
+	expira  time.Time
+}
+
+// Cache en memoria de resultados de busqueda, compartida por /search y el
+// calendario de precios
+var (
+	cacheBusquedas   = make(map[string]entradaCache)
+	muCacheBusquedas sync.Mutex
+)
+
+func (p ParametrosBusqueda) query() url.Values {
+	params := map[string]string{
+		"originLocationCode":      p.Origen,
+		"destinationLocationCode": p.Destino,
+		"departureDate":           p.FechaSalida,
+		"adults":                  p.Adultos,
+		"includedAirlineCodes":    "H2,LA,JA",
+		"nonStop":                 "true",
+		"currencyCode":            p.moneda(),
+		"travelClass":             "ECONOMY",
+	}
+	if p.FechaRegreso != "" {
+		params["returnDate"] = p.FechaRegreso
+	}
+	if cantidad(p.Ninos) > 0 {
+		params["children"] = p.Ninos
+	}
+	if cantidad(p.Infantes) > 0 {
+		params["infants"] = p.Infantes
+	}
+	if cantidad(p.Mayores) > 0 {
+		params["seniors"] = p.Mayores
+	}
+
+	query := url.Values{}
+
+	// Agregar los parámetros al objeto url.Values
+	for key, value := range params {
+		query.Add(key, value)
+	}
+	return query
+}
+
+func buscarEnCache(clave string) ([]FlightOffer, bool) {
+	muCacheBusquedas.Lock()
+	defer muCacheBusquedas.Unlock()
+
+	entrada, ok := cacheBusquedas[clave]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entrada.expira) {
+		delete(cacheBusquedas, clave)
+		return nil, false
+	}
+	return entrada.ofertas, true
+}
+
+func guardarEnCache(clave string, ofertas []FlightOffer) {
+	muCacheBusquedas.Lock()
+	defer muCacheBusquedas.Unlock()
+
+	// Se aprovecha cada escritura para descartar las entradas vencidas
+	ahora := time.Now()
+	for otra, entrada := range cacheBusquedas {
+		if ahora.After(entrada.expira) {
+			delete(cacheBusquedas, otra)
+		}
+	}
+	cacheBusquedas[clave] = entradaCache{ofertas: ofertas, expira: ahora.Add(vigenciaCacheBusqueda)}
+}
+
+// Cuerpo de la busqueda POST de Amadeus. Los pasajeros se numeran en el
+// mismo orden que usa la busqueda GET: adultos, mayores, ninos e infantes.
+// Cada infante se asocia a un adulto o mayor distinto
+func (p ParametrosBusqueda) cuerpo() map[string]interface{} {
+	var viajeros []map[string]interface{}
+	agregar := func(tipo string, n int) {
+		for i := 0; i < n; i++ {
+			viajeros = append(viajeros, map[string]interface{}{
+				"id":           strconv.Itoa(len(viajeros) + 1),
+				"travelerType": tipo,
+			})
+		}
+	}
+	agregar("ADULT", cantidad(p.Adultos))
+	agregar("SENIOR", cantidad(p.Mayores))
+	agregar("CHILD", cantidad(p.Ninos))
+	for i := 0; i < cantidad(p.Infantes); i++ {
+		viajeros = append(viajeros, map[string]interface{}{
+			"id":                strconv.Itoa(len(viajeros) + 1),
+			"travelerType":      "HELD_INFANT",
+			"associatedAdultId": strconv.Itoa(i + 1),
+		})
+	}
+
+	origenesDestinos := []map[string]interface{}{{
+		"id":                      "1",
+		"originLocationCode":      p.Origen,
+		"destinationLocationCode": p.Destino,
+		"departureDateTimeRange":  map[string]string{"date": p.FechaSalida},
+	}}
+	tramos := []string{"1"}
+	if p.FechaRegreso != "" {
+		origenesDestinos = append(origenesDestinos, map[string]interface{}{
+			"id":                      "2",
+			"originLocationCode":      p.Destino,
+			"destinationLocationCode": p.Origen,
+			"departureDateTimeRange":  map[string]string{"date": p.FechaRegreso},
+		})
+		tramos = append(tramos, "2")
+	}
+
+	// Mismos filtros que la busqueda GET
+	return map[string]interface{}{
+		"currencyCode":       p.moneda(),
+		"originDestinations": origenesDestinos,
+		"travelers":          viajeros,
+		"sources":            []string{"GDS"},
+		"searchCriteria": map[string]interface{}{
+			"flightFilters": map[string]interface{}{
+				"carrierRestrictions": map[string]interface{}{
+					"includedCarrierCodes": []string{"H2", "LA", "JA"},
+				},
+				"cabinRestrictions": []map[string]interface{}{{
+					"cabin":                "ECONOMY",
+					"coverage":             "MOST_SEGMENTS",
+					"originDestinationIds": tramos,
+				}},
+				"connectionRestriction": map[string]interface{}{
+					"maxNumberOfConnections": 0,
+				},
+			},
+		},
+	}
+}
+
+// Busca ofertas de vuelo en Amadeus, reutilizando resultados recientes
+func consultarVuelos(ctx context.Context, parametros ParametrosBusqueda) ([]FlightOffer, error) {
+	agencia := agenciaDe(ctx)
+	if agencia != nil {
+		parametros.Moneda = agencia.Moneda
+	}
+	query := parametros.query()
+	clave := query.Encode()
+	// Cada agencia tiene sus propias tarifas, por lo que no comparten cache
+	claveCache := clave
+	if agencia != nil {
+		claveCache = agencia.Id + "|" + clave
+	}
+	if ofertas, ok := buscarEnCache(claveCache); ok {
+		return ofertas, nil
+	}
+
+	token, err := obtenerToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error al obtener el token: %w", err)
+	}
+
+	var req *http.Request
+	if cantidad(parametros.Mayores) > 0 {
+		// La busqueda por GET no admite mayores, por lo que se usa la
+		// version POST que recibe cada pasajero con su tipo
+		cuerpo, err := json.Marshal(parametros.cuerpo())
+		if err != nil {
+			return nil, err
+		}
+		req, err = http.NewRequestWithContext(ctx, "POST", config.URLProveedor+"/v2/shopping/flight-offers", bytes.NewBuffer(cuerpo))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+	} else {
+		fullUrl := config.URLProveedor + "/v2/shopping/flight-offers?" + clave
+		// Crear una solicitud GET
+		req, err = http.NewRequestWithContext(ctx, "GET", fullUrl, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	req.Header.Add("Authorization", "Bearer "+token)
+
+	// Realizar la solicitud con la instancia de http.Client personalizada
+	resp, err := clienteAmadeus.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Leer la respuesta
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("Amadeus respondió %d: %s", resp.StatusCode, body)
+	}
+
+	var response FlightOffersResponse
+	//Deserializar respuesta
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+
+	guardarEnCache(claveCache, response.Data)
+	return response.Data, nil
+}
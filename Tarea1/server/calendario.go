@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Formato de fecha y hora local que entrega Amadeus en cada segmento
+const formatoAmadeus = "2006-01-02T15:04:05"
+
+func exportarCalendario(c *gin.Context) {
+	id := c.Param("id")
+
+	reserva, err := buscarReservaGuardada(c.Request.Context(), id)
+	if errors.Is(err, errReservaNoEncontrada) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reserva no encontrada"})
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error al buscar la reserva", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al buscar la reserva"})
+		return
+	}
+
+	ics, err := generarICS(reserva, time.Now())
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error al generar el calendario", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="reserva.ics"`)
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(ics))
+}
+
+// Genera un calendario iCalendar (RFC 5545) con un VEVENT por cada segmento
+// de la reserva. Las horas locales de cada aeropuerto se convierten a UTC
+// usando su zona horaria
+func generarICS(reserva Booking, ahora time.Time) (string, error) {
+	var pnr []string
+	for _, registro := range reserva.Data.AssociatedRecords {
+		pnr = append(pnr, registro.Reference)
+	}
+
+	var b strings.Builder
+	escribirLinea(&b, "BEGIN:VCALENDAR")
+	escribirLinea(&b, "VERSION:2.0")
+	escribirLinea(&b, "PRODID:-//goTravel//Tarea1 INF343//ES")
+	escribirLinea(&b, "CALSCALE:GREGORIAN")
+	escribirLinea(&b, "METHOD:PUBLISH")
+
+	for _, offer := range reserva.Data.FlightOffers {
+		for _, itinerario := range offer.Itineraries {
+			for _, segmento := range itinerario.Segments {
+				inicio, err := fechaICS(segmento.Departure.IataCode, segmento.Departure.At)
+				if err != nil {
+					return "", err
+				}
+				fin, err := fechaICS(segmento.Arrival.IataCode, segmento.Arrival.At)
+				if err != nil {
+					return "", err
+				}
+
+				vuelo := segmento.CarrierCode + segmento.Number
+				descripcion := fmt.Sprintf("Vuelo %s de %s a %s\nAvión: %s\nDuración: %s",
+					vuelo,
+					nombreAeropuerto(segmento.Departure.IataCode),
+					nombreAeropuerto(segmento.Arrival.IataCode),
+					segmento.Aircraft.Code,
+					segmento.Duration)
+				if len(pnr) > 0 {
+					descripcion += "\nCódigo de reserva (PNR): " + strings.Join(pnr, ", ")
+				}
+
+				escribirLinea(&b, "BEGIN:VEVENT")
+				escribirLinea(&b, "UID:"+reserva.Data.Id+"-"+offer.Id+"-"+segmento.Id+"@gotravel")
+				escribirLinea(&b, "DTSTAMP:"+ahora.UTC().Format("20060102T150405Z"))
+				escribirLinea(&b, "DTSTART"+inicio)
+				escribirLinea(&b, "DTEND"+fin)
+				escribirLinea(&b, "SUMMARY:"+escaparTextoICS("Vuelo "+vuelo+" "+segmento.Departure.IataCode+" → "+segmento.Arrival.IataCode))
+				escribirLinea(&b, "LOCATION:"+escaparTextoICS(nombreAeropuerto(segmento.Departure.IataCode)))
+				escribirLinea(&b, "DESCRIPTION:"+escaparTextoICS(descripcion))
+				escribirLinea(&b, "END:VEVENT")
+			}
+		}
+	}
+
+	escribirLinea(&b, "END:VCALENDAR")
+	return b.String(), nil
+}
+
+// Convierte una hora local de Amadeus en la propiedad de fecha del evento.
+// Si no se conoce la zona horaria del aeropuerto se usa hora flotante
+func fechaICS(iata string, fecha string) (string, error) {
+	aeropuerto, ok := buscarAeropuerto(iata)
+	if !ok {
+		local, err := time.Parse(formatoAmadeus, fecha)
+		if err != nil {
+			return "", err
+		}
+		return ":" + local.Format("20060102T150405"), nil
+	}
+
+	local, err := time.ParseInLocation(formatoAmadeus, fecha, aeropuerto.ZonaHoraria)
+	if err != nil {
+		return "", err
+	}
+	return ":" + local.UTC().Format("20060102T150405Z"), nil
+}
+
+func nombreAeropuerto(iata string) string {
+	aeropuerto, ok := buscarAeropuerto(iata)
+	if !ok {
+		return iata
+	}
+	return fmt.Sprintf("%s, %s (%s)", aeropuerto.Nombre, aeropuerto.Ciudad, iata)
+}
+
+func escaparTextoICS(texto string) string {
+	reemplazos := strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\n", `\n`,
+	)
+	return reemplazos.Replace(texto)
+}
+
+// Escribe una linea de contenido terminada en CRLF, plegandola cada 75
+// octetos como exige el formato sin cortar caracteres UTF-8
+func escribirLinea(b *strings.Builder, linea string) {
+	limite := 75
+	for len(linea) > limite {
+		corte := limite
+		for corte > 0 && !inicioRuna(linea[corte]) {
+			corte--
+		}
+		b.WriteString(linea[:corte])
+		b.WriteString("\r\n ")
+		linea = linea[corte:]
+		// Las lineas de continuacion comienzan con un espacio
+		limite = 74
+	}
+	b.WriteString(linea)
+	b.WriteString("\r\n")
+}
+
+func inicioRuna(octeto byte) bool {
+	return octeto&0xC0 != 0x80
+}
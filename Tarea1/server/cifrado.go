@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Prefijo de los valores cifrados: enc:v1:<id de clave>:<nonce y texto
+// cifrado en base64>. Los valores sin prefijo se leen tal cual, como los de
+// reservas guardadas antes de configurar una clave
+const prefijoCifrado = "enc:v1:"
+
+// Claves para cifrar los datos personales de las reservas. Se cifra siempre
+// con la activa (la primera configurada); las demas se conservan para leer
+// reservas cifradas antes de rotarla
+type ClavesPII struct {
+	activa string
+	claves map[string]cipher.AEAD
+}
+
+// Claves cargadas al iniciar. Si es nil los datos se guardan sin cifrar
+var clavesPII *ClavesPII
+
+// Lee las claves con el formato id:base64,id:base64 (cada una de 32 bytes,
+// por ejemplo generada con openssl rand -base64 32)
+func cargarClavesPII(texto string) (*ClavesPII, error) {
+	if strings.TrimSpace(texto) == "" {
+		return nil, nil
+	}
+
+	claves := &ClavesPII{claves: make(map[string]cipher.AEAD)}
+	for _, par := range strings.Split(texto, ",") {
+		id, valor, ok := strings.Cut(strings.TrimSpace(par), ":")
+		if !ok || id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("clave de cifrado no válida: use id:base64")
+		}
+		secreto, err := base64.StdEncoding.DecodeString(valor)
+		if err != nil || len(secreto) != 32 {
+			return nil, fmt.Errorf("la clave %s debe ser de 32 bytes en base64", id)
+		}
+		bloque, err := aes.NewCipher(secreto)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(bloque)
+		if err != nil {
+			return nil, err
+		}
+		if _, repetida := claves.claves[id]; repetida {
+			return nil, fmt.Errorf("la clave %s está repetida", id)
+		}
+		claves.claves[id] = aead
+		if claves.activa == "" {
+			claves.activa = id
+		}
+	}
+	return claves, nil
+}
+
+func (k *ClavesPII) cifrar(valor string) (string, error) {
+	if k == nil || valor == "" || strings.HasPrefix(valor, prefijoCifrado+k.activa+":") {
+		return valor, nil
+	}
+	// Un valor cifrado con una clave anterior se descifra antes de volver a
+	// cifrarlo con la activa
+	valor, err := k.descifrar(valor)
+	if err != nil {
+		return "", err
+	}
+
+	aead := k.claves[k.activa]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	// El id de la clave se autentica junto al texto para que no se pueda
+	// mover un valor a otra clave
+	cifrado := aead.Seal(nonce, nonce, []byte(valor), []byte(k.activa))
+	return prefijoCifrado + k.activa + ":" + base64.StdEncoding.EncodeToString(cifrado), nil
+}
+
+func (k *ClavesPII) descifrar(valor string) (string, error) {
+	if !strings.HasPrefix(valor, prefijoCifrado) {
+		return valor, nil
+	}
+	if k == nil {
+		return "", fmt.Errorf("hay datos cifrados pero no se configuró PII_KEYS")
+	}
+
+	id, datos, _ := strings.Cut(strings.TrimPrefix(valor, prefijoCifrado), ":")
+	aead, ok := k.claves[id]
+	if !ok {
+		return "", fmt.Errorf("no se encontró la clave de cifrado %s", id)
+	}
+	cifrado, err := base64.StdEncoding.DecodeString(datos)
+	if err != nil || len(cifrado) < aead.NonceSize() {
+		return "", fmt.Errorf("valor cifrado no válido")
+	}
+	texto, err := aead.Open(nil, cifrado[:aead.NonceSize()], cifrado[aead.NonceSize():], []byte(id))
+	if err != nil {
+		return "", fmt.Errorf("no se pudo descifrar con la clave %s: %w", id, err)
+	}
+	return string(texto), nil
+}
+
+// Aplica f a cada dato personal de los pasajeros. Los pasajeros, telefonos
+// y documentos se copian para no modificar la reserva original
+func transformarPasajeros(viajeros []Travelers, f func(string) (string, error)) ([]Travelers, error) {
+	resultado := make([]Travelers, len(viajeros))
+	for i, viajero := range viajeros {
+		campos := []*string{
+			&viajero.DateOfBirth,
+			&viajero.Gender,
+			&viajero.Name.FirstName,
+			&viajero.Name.LastName,
+			&viajero.Contact.EmailAddress,
+		}
+
+		viajero.Contact.Phones = append([]Phones(nil), viajero.Contact.Phones...)
+		for j := range viajero.Contact.Phones {
+			telefono := &viajero.Contact.Phones[j]
+			campos = append(campos, &telefono.CountryCallingCode, &telefono.Number)
+		}
+		viajero.Documents = append([]Documents(nil), viajero.Documents...)
+		for j := range viajero.Documents {
+			documento := &viajero.Documents[j]
+			campos = append(campos, &documento.Number, &documento.BirthPlace, &documento.IssuanceLocation, &documento.IssuanceDate, &documento.ExpiryDate)
+		}
+
+		for _, campo := range campos {
+			valor, err := f(*campo)
+			if err != nil {
+				return nil, fmt.Errorf("pasajero %s: %w", viajero.Id, err)
+			}
+			*campo = valor
+		}
+		resultado[i] = viajero
+	}
+	return resultado, nil
+}
+
+// Devuelve una copia de la reserva con los datos personales cifrados
+func cifrarReserva(reserva Booking) (Booking, error) {
+	viajeros, err := transformarPasajeros(reserva.Data.Travelers, clavesPII.cifrar)
+	if err != nil {
+		return Booking{}, err
+	}
+	reserva.Data.Travelers = viajeros
+	return reserva, nil
+}
+
+// Descifra los datos personales de una reserva leida de MongoDB
+func descifrarReserva(reserva *Booking) error {
+	viajeros, err := transformarPasajeros(reserva.Data.Travelers, clavesPII.descifrar)
+	if err != nil {
+		return err
+	}
+	reserva.Data.Travelers = viajeros
+	return nil
+}
+
+// Vuelve a cifrar con la clave activa todo lo cifrado con clavesPII: los
+// pasajeros y las respuestas guardadas para revision de las reservas, y
+// los cuerpos de las solicitudes de aprobacion. Se usa despues de agregar
+// una clave nueva, antes de retirar la anterior
+func rotarClavesPII(c *gin.Context) {
+	if clavesPII == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "No hay claves de cifrado configuradas (PII_KEYS)"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	clientDB, err := conectarMongo(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al conectar con la base de datos"})
+		return
+	}
+	defer clientDB.Disconnect(context.TODO())
+	baseDatos := clientDB.Database(baseDeDatos)
+
+	actualizadas, fallidas, err := rotarReservas(ctx, baseDatos.Collection(coleccionReservasDe(ctx)))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	solicitudesActualizadas, solicitudesFallidas, err := rotarSolicitudes(ctx, baseDatos.Collection(coleccionAprobacionesDe(ctx)))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"claveActiva":             clavesPII.activa,
+		"actualizadas":            actualizadas,
+		"fallidas":                fallidas,
+		"solicitudesActualizadas": solicitudesActualizadas,
+		"solicitudesFallidas":     solicitudesFallidas,
+	})
+}
+
+// Vuelve a cifrar los pasajeros y la respuesta cruda de cada reserva.
+// Devuelve las reservas actualizadas y las que fallaron
+func rotarReservas(ctx context.Context, collection *mongo.Collection) (int, int, error) {
+	cursor, err := collection.Find(ctx, bson.M{})
+	if err != nil {
+		return 0, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	actualizadas, fallidas := 0, 0
+	for cursor.Next(ctx) {
+		var documento struct {
+			Id      interface{} `bson:"_id"`
+			Booking `bson:",inline"`
+			Crudo   string `bson:"crudo"`
+		}
+		if err := cursor.Decode(&documento); err != nil {
+			fallidas++
+			continue
+		}
+
+		set := bson.M{}
+		if len(documento.Data.Travelers) > 0 {
+			cifrada, err := cifrarReserva(documento.Booking)
+			if err != nil {
+				slog.ErrorContext(ctx, "Error al volver a cifrar la reserva", "reserva", documento.Data.Id, "error", err)
+				fallidas++
+				continue
+			}
+			set["data.travelers"] = cifrada.Data.Travelers
+		}
+		if documento.Crudo != "" {
+			crudo, err := clavesPII.cifrar(documento.Crudo)
+			if err != nil {
+				slog.ErrorContext(ctx, "Error al volver a cifrar la respuesta guardada", "reserva", documento.Data.Id, "error", err)
+				fallidas++
+				continue
+			}
+			set["crudo"] = crudo
+		}
+		if len(set) == 0 {
+			continue
+		}
+		if _, err := collection.UpdateOne(ctx, bson.M{"_id": documento.Id}, bson.M{"$set": set}); err != nil {
+			fallidas++
+			continue
+		}
+		actualizadas++
+	}
+	return actualizadas, fallidas, cursor.Err()
+}
+
+// Vuelve a cifrar el cuerpo de las solicitudes de aprobacion que aun lo
+// guardan. Devuelve las solicitudes actualizadas y las que fallaron
+func rotarSolicitudes(ctx context.Context, collection *mongo.Collection) (int, int, error) {
+	cursor, err := collection.Find(ctx, bson.M{"cuerpo": bson.M{"$exists": true}})
+	if err != nil {
+		return 0, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	actualizadas, fallidas := 0, 0
+	for cursor.Next(ctx) {
+		var solicitud SolicitudAprobacion
+		if err := cursor.Decode(&solicitud); err != nil {
+			fallidas++
+			continue
+		}
+		cuerpo, err := clavesPII.cifrar(solicitud.Cuerpo)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error al volver a cifrar la solicitud de aprobación", "solicitud", solicitud.Id, "error", err)
+			fallidas++
+			continue
+		}
+		// Solo si el cuerpo no cambio: al resolverse la solicitud se elimina
+		filtro := bson.M{"_id": solicitud.Id, "cuerpo": solicitud.Cuerpo}
+		if _, err := collection.UpdateOne(ctx, filtro, bson.M{"$set": bson.M{"cuerpo": cuerpo}}); err != nil {
+			fallidas++
+			continue
+		}
+		actualizadas++
+	}
+	return actualizadas, fallidas, cursor.Err()
+}
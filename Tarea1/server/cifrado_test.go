@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// Claves de 32 bytes en base64 para los tests
+const (
+	clavePruebaA = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+	clavePruebaB = "ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA="
+)
+
+func cargarClavesPrueba(t *testing.T, texto string) *ClavesPII {
+	t.Helper()
+	claves, err := cargarClavesPII(texto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return claves
+}
+
+func TestCifrarYDescifrar(t *testing.T) {
+	claves := cargarClavesPrueba(t, "a:"+clavePruebaA)
+
+	cifrado, err := claves.cifrar("ANA PEREZ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(cifrado, prefijoCifrado+"a:") || strings.Contains(cifrado, "ANA") {
+		t.Fatalf("cifrado = %q", cifrado)
+	}
+	if texto, err := claves.descifrar(cifrado); err != nil || texto != "ANA PEREZ" {
+		t.Errorf("descifrar = %q, %v", texto, err)
+	}
+
+	// Un valor ya cifrado con la clave activa no se vuelve a cifrar, y los
+	// valores vacios o sin cifrar se leen tal cual
+	if otra, _ := claves.cifrar(cifrado); otra != cifrado {
+		t.Errorf("se volvió a cifrar un valor con la clave activa")
+	}
+	if vacio, _ := claves.cifrar(""); vacio != "" {
+		t.Errorf("cifrar(\"\") = %q", vacio)
+	}
+	if texto, err := claves.descifrar("sin cifrar"); err != nil || texto != "sin cifrar" {
+		t.Errorf("descifrar sin cifrar = %q, %v", texto, err)
+	}
+
+	var sinClaves *ClavesPII
+	if _, err := sinClaves.descifrar(cifrado); err == nil {
+		t.Error("se descifró sin claves configuradas")
+	}
+}
+
+func TestDescifrarRechazaOtroIdDeClave(t *testing.T) {
+	// Con la misma clave bajo dos ids, mover el valor al otro id debe fallar
+	// porque el id se autentica junto al texto
+	claves := cargarClavesPrueba(t, "a:"+clavePruebaA+",b:"+clavePruebaA)
+	cifrado, err := claves.cifrar("ANA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	movido := strings.Replace(cifrado, prefijoCifrado+"a:", prefijoCifrado+"b:", 1)
+	if texto, err := claves.descifrar(movido); err == nil {
+		t.Errorf("se descifró un valor movido a otra clave: %q", texto)
+	}
+}
+
+func TestCifrarConLaClaveActiva(t *testing.T) {
+	anterior := cargarClavesPrueba(t, "vieja:"+clavePruebaA)
+	cifrado, err := anterior.cifrar("ANA")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Tras la rotacion la clave nueva queda primera y la vieja solo se lee
+	rotadas := cargarClavesPrueba(t, "nueva:"+clavePruebaB+",vieja:"+clavePruebaA)
+	recifrado, err := rotadas.cifrar(cifrado)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(recifrado, prefijoCifrado+"nueva:") {
+		t.Fatalf("recifrado = %q, se esperaba la clave nueva", recifrado)
+	}
+	soloNueva := cargarClavesPrueba(t, "nueva:"+clavePruebaB)
+	if texto, err := soloNueva.descifrar(recifrado); err != nil || texto != "ANA" {
+		t.Errorf("descifrar sin la clave vieja = %q, %v", texto, err)
+	}
+}
+
+func TestCargarClavesPIIRechazaEntradasInvalidas(t *testing.T) {
+	if claves, err := cargarClavesPII(" "); claves != nil || err != nil {
+		t.Errorf("sin claves = %v, %v", claves, err)
+	}
+
+	casos := map[string]string{
+		"sin id":        clavePruebaA,
+		"id vacio":      ":" + clavePruebaA,
+		"no es base64":  "a:no-es-base64!",
+		"muy corta":     "a:MDEyMzQ1Njc4OWFiY2RlZg==",
+		"repetida":      "a:" + clavePruebaA + ",a:" + clavePruebaB,
+		"dos puntos":    "a:b:" + clavePruebaA,
+		"segunda vacia": "a:" + clavePruebaA + ",",
+	}
+	for nombre, texto := range casos {
+		if _, err := cargarClavesPII(texto); err == nil {
+			t.Errorf("%s: se aceptó %q", nombre, texto)
+		}
+	}
+}
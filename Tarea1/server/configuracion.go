@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Configuracion del servidor. Cada valor se toma, de menor a mayor
+// prioridad, desde los valores por defecto, el archivo de configuracion
+// (JSON), las variables de entorno (incluido .env) y los flags
+type Configuracion struct {
+	Servidor      string        `json:"server"`
+	Puerto        string        `json:"port"`
+	URLProveedor  string        `json:"amadeusUrl"`
+	ClientID      string        `json:"clientId"`
+	ClientSecret  string        `json:"clientSecret"`
+	MongoURI      string        `json:"mongoUri"`
+	TiempoApagado time.Duration `json:"-"`
+
+	// Plazo de cada proveedor en una busqueda. Los que no responden a
+	// tiempo se omiten y se informan como advertencia
+	TiempoProveedor time.Duration `json:"-"`
+
+	// Modo de acceso al proveedor: vacio para llamar a Amadeus, record para
+	// grabar cada llamada como fixture, replay para responder desde ellas o
+	// fake-seatmaps para simular los mapas de asientos
+	ModoProveedor      string `json:"providerMode"`
+	DirectorioFixtures string `json:"providerFixtures"`
+
+	// Claves para cifrar los datos personales de las reservas (id:base64,
+	// la primera es la activa)
+	ClavesPII string `json:"piiKeys"`
+
+	// Tiempo despues del viaje tras el cual los datos personales de una
+	// reserva se anonimizan o, con ModoRetencion purge, la reserva se
+	// elimina. Cero desactiva la retencion
+	Retencion     time.Duration `json:"-"`
+	ModoRetencion string        `json:"retentionMode"`
+
+	// Cada cuanto se consultan en el proveedor las reservas activas para
+	// detectar cambios. Cero desactiva la sincronizacion
+	IntervaloSincronizacion time.Duration `json:"-"`
+
+	// Anticipacion con la que se avisa que una reserva sin boletos se acerca
+	// a su ultimo dia de emision. Cero desactiva los avisos
+	AvisoEmision time.Duration `json:"-"`
+
+	// Archivo JSON con la politica de viajes. Vacio desactiva la politica
+	ArchivoPolitica string `json:"policyFile"`
+
+	// Tiempo que se mantiene el precio cotizado cuando la oferta no indica
+	// su ultimo dia de emision. Una reserva fuera de la politica que no se
+	// aprueba en ese plazo vence
+	VigenciaPrecio time.Duration `json:"-"`
+
+	// Correos de los aprobadores, separados por coma
+	Aprobadores string `json:"approvers"`
+
+	// Archivo JSON con las agencias que comparten el servidor. Vacio usa una
+	// sola agencia con las credenciales y la politica globales
+	ArchivoAgencias string `json:"tenantsFile"`
+
+	// Archivos con las credenciales de Amadeus (secretos montados). Tienen
+	// prioridad sobre ClientID y ClientSecret y se revisan cada
+	// IntervaloSecretos para rotarlas sin reiniciar. Cero no los revisa
+	ArchivoClientID     string        `json:"clientIdFile"`
+	ArchivoClientSecret string        `json:"clientSecretFile"`
+	IntervaloSecretos   time.Duration `json:"-"`
+
+	// Nivel minimo de los registros: debug, info, warn o error
+	NivelRegistro string `json:"logLevel"`
+
+	// Token de las rutas /admin. Vacio las deshabilita
+	TokenAdministrador string `json:"adminToken"`
+
+	// Envio de correos: smtp usa el servidor indicado; cualquier otro valor
+	// escribe los correos en ArchivoCorreos
+	Mailer         string `json:"mailer"`
+	ArchivoCorreos string `json:"mailerFile"`
+	SMTPHost       string `json:"smtpHost"`
+	SMTPPuerto     string `json:"smtpPort"`
+	SMTPUsuario    string `json:"smtpUser"`
+	SMTPClave      string `json:"smtpPassword"`
+	SMTPRemitente  string `json:"smtpFrom"`
+}
+
+var config Configuracion
+
+func configuracionPorDefecto() Configuracion {
+	return Configuracion{
+		Servidor:      "localhost",
+		Puerto:        "5000",
+		URLProveedor:  "https://test.api.amadeus.com",
+		MongoURI:      "mongodb://localhost:27017",
+		TiempoApagado: 30 * time.Second,
+
+		TiempoProveedor: 15 * time.Second,
+
+		DirectorioFixtures: "testdata/amadeus",
+
+		Retencion:     90 * 24 * time.Hour,
+		ModoRetencion: modoRetencionAnonimizar,
+
+		IntervaloSincronizacion: 30 * time.Minute,
+		AvisoEmision:            48 * time.Hour,
+
+		VigenciaPrecio: 30 * time.Minute,
+
+		IntervaloSecretos: 30 * time.Second,
+
+		SMTPPuerto: "25",
+	}
+}
+
+// Direccion en la que escucha el servidor
+func (c Configuracion) Direccion() string {
+	return c.Servidor + ":" + c.Puerto
+}
+
+func cargarConfiguracion(args []string) (Configuracion, error) {
+	cfg := configuracionPorDefecto()
+
+	flags := flag.NewFlagSet("server", flag.ContinueOnError)
+	archivo := flags.String("config", os.Getenv("CONFIG_FILE"), "archivo de configuración JSON")
+	servidor := flags.String("server", "", "dirección en la que escucha el servidor")
+	puerto := flags.String("port", "", "puerto en el que escucha el servidor")
+	urlProveedor := flags.String("amadeus-url", "", "URL base de la API de Amadeus")
+	mongoURI := flags.String("mongo-uri", "", "cadena de conexión de MongoDB")
+	tiempoApagado := flags.Duration("shutdown-timeout", 0, "tiempo máximo para terminar las solicitudes en curso al apagar")
+	modoProveedor := flags.String("provider-mode", "", "modo de acceso al proveedor: record, replay o fake-seatmaps")
+	directorioFixtures := flags.String("provider-fixtures", "", "directorio de fixtures del proveedor")
+	clientID := flags.String("client-id", "", "CLIENT_ID de Amadeus")
+	clientSecret := flags.String("client-secret", "", "SECRECT_ID de Amadeus")
+	archivoClientID := flags.String("client-id-file", "", "archivo con el CLIENT_ID de Amadeus")
+	archivoClientSecret := flags.String("client-secret-file", "", "archivo con el SECRECT_ID de Amadeus")
+	nivelRegistro := flags.String("log-level", "", "nivel mínimo de los registros: debug, info, warn o error")
+	archivoAgencias := flags.String("tenants", "", "archivo JSON con las agencias")
+	archivoPolitica := flags.String("policy", "", "archivo JSON con la política de viajes")
+	tiempoProveedor := flags.Duration("provider-timeout", 0, "tiempo máximo de espera de cada proveedor en una búsqueda")
+	if err := flags.Parse(args); err != nil {
+		return Configuracion{}, err
+	}
+
+	if *archivo != "" {
+		contenido, err := os.ReadFile(*archivo)
+		if err != nil {
+			return Configuracion{}, fmt.Errorf("no se pudo leer el archivo de configuración: %w", err)
+		}
+		if err := json.Unmarshal(contenido, &cfg); err != nil {
+			return Configuracion{}, fmt.Errorf("archivo de configuración no válido: %w", err)
+		}
+	}
+
+	sobrescribir(&cfg.Servidor, os.Getenv("SERVER"))
+	sobrescribir(&cfg.Puerto, os.Getenv("PORT"))
+	sobrescribir(&cfg.URLProveedor, os.Getenv("AMADEUS_URL"))
+	sobrescribir(&cfg.ClientID, os.Getenv("CLIENT_ID"))
+	sobrescribir(&cfg.ClientSecret, os.Getenv("SECRECT_ID"))
+	sobrescribir(&cfg.ArchivoClientID, os.Getenv("CLIENT_ID_FILE"))
+	sobrescribir(&cfg.ArchivoClientSecret, os.Getenv("SECRECT_ID_FILE"))
+	if valor := os.Getenv("SECRETS_RELOAD_INTERVAL"); valor != "" {
+		duracion, err := time.ParseDuration(valor)
+		if err != nil {
+			return Configuracion{}, fmt.Errorf("SECRETS_RELOAD_INTERVAL no válido: %w", err)
+		}
+		cfg.IntervaloSecretos = duracion
+	}
+	sobrescribir(&cfg.MongoURI, os.Getenv("CONNECTION_STRING"))
+	sobrescribir(&cfg.ModoProveedor, os.Getenv("PROVIDER_MODE"))
+	sobrescribir(&cfg.DirectorioFixtures, os.Getenv("PROVIDER_FIXTURES"))
+	if valor := os.Getenv("SHUTDOWN_TIMEOUT"); valor != "" {
+		duracion, err := time.ParseDuration(valor)
+		if err != nil {
+			return Configuracion{}, fmt.Errorf("SHUTDOWN_TIMEOUT no válido: %w", err)
+		}
+		cfg.TiempoApagado = duracion
+	}
+	sobrescribir(&cfg.ClavesPII, os.Getenv("PII_KEYS"))
+	sobrescribir(&cfg.ArchivoPolitica, os.Getenv("TRAVEL_POLICY"))
+	sobrescribir(&cfg.Aprobadores, os.Getenv("APPROVERS"))
+	sobrescribir(&cfg.ArchivoAgencias, os.Getenv("TENANTS_FILE"))
+	sobrescribir(&cfg.NivelRegistro, os.Getenv("LOG_LEVEL"))
+	sobrescribir(&cfg.TokenAdministrador, os.Getenv("ADMIN_TOKEN"))
+	sobrescribir(&cfg.Mailer, os.Getenv("MAILER"))
+	sobrescribir(&cfg.ArchivoCorreos, os.Getenv("MAILER_ARCHIVO"))
+	sobrescribir(&cfg.SMTPHost, os.Getenv("SMTP_HOST"))
+	sobrescribir(&cfg.SMTPPuerto, os.Getenv("SMTP_PORT"))
+	sobrescribir(&cfg.SMTPUsuario, os.Getenv("SMTP_USER"))
+	sobrescribir(&cfg.SMTPClave, os.Getenv("SMTP_PASSWORD"))
+	sobrescribir(&cfg.SMTPRemitente, os.Getenv("SMTP_FROM"))
+	if valor := os.Getenv("PRICE_VALIDITY"); valor != "" {
+		duracion, err := time.ParseDuration(valor)
+		if err != nil || duracion <= 0 {
+			return Configuracion{}, fmt.Errorf("PRICE_VALIDITY no válido: %q", valor)
+		}
+		cfg.VigenciaPrecio = duracion
+	}
+	sobrescribir(&cfg.ModoRetencion, os.Getenv("RETENTION_MODE"))
+	if valor := os.Getenv("RETENTION_PERIOD"); valor != "" {
+		duracion, err := time.ParseDuration(valor)
+		if err != nil {
+			return Configuracion{}, fmt.Errorf("RETENTION_PERIOD no válido: %w", err)
+		}
+		cfg.Retencion = duracion
+	}
+	if valor := os.Getenv("SYNC_INTERVAL"); valor != "" {
+		duracion, err := time.ParseDuration(valor)
+		if err != nil {
+			return Configuracion{}, fmt.Errorf("SYNC_INTERVAL no válido: %w", err)
+		}
+		cfg.IntervaloSincronizacion = duracion
+	}
+	if valor := os.Getenv("TICKETING_WARNING"); valor != "" {
+		duracion, err := time.ParseDuration(valor)
+		if err != nil {
+			return Configuracion{}, fmt.Errorf("TICKETING_WARNING no válido: %w", err)
+		}
+		cfg.AvisoEmision = duracion
+	}
+	if valor := os.Getenv("PROVIDER_TIMEOUT"); valor != "" {
+		duracion, err := time.ParseDuration(valor)
+		if err != nil {
+			return Configuracion{}, fmt.Errorf("PROVIDER_TIMEOUT no válido: %w", err)
+		}
+		cfg.TiempoProveedor = duracion
+	}
+
+	sobrescribir(&cfg.Servidor, *servidor)
+	sobrescribir(&cfg.Puerto, *puerto)
+	sobrescribir(&cfg.URLProveedor, *urlProveedor)
+	sobrescribir(&cfg.MongoURI, *mongoURI)
+	sobrescribir(&cfg.ClientID, *clientID)
+	sobrescribir(&cfg.ClientSecret, *clientSecret)
+	sobrescribir(&cfg.ArchivoClientID, *archivoClientID)
+	sobrescribir(&cfg.ArchivoClientSecret, *archivoClientSecret)
+	sobrescribir(&cfg.ModoProveedor, *modoProveedor)
+	sobrescribir(&cfg.DirectorioFixtures, *directorioFixtures)
+	sobrescribir(&cfg.ArchivoPolitica, *archivoPolitica)
+	sobrescribir(&cfg.ArchivoAgencias, *archivoAgencias)
+	sobrescribir(&cfg.NivelRegistro, *nivelRegistro)
+	if *tiempoApagado > 0 {
+		cfg.TiempoApagado = *tiempoApagado
+	}
+	if *tiempoProveedor > 0 {
+		cfg.TiempoProveedor = *tiempoProveedor
+	}
+
+	if cfg.Mailer == mailerSMTP && (cfg.SMTPHost == "" || cfg.SMTPRemitente == "") {
+		return Configuracion{}, fmt.Errorf("MAILER=smtp requiere SMTP_HOST y SMTP_FROM")
+	}
+
+	if cfg.ModoRetencion != modoRetencionAnonimizar && cfg.ModoRetencion != modoRetencionEliminar {
+		return Configuracion{}, fmt.Errorf("RETENTION_MODE no válido: %q (use %s o %s)", cfg.ModoRetencion, modoRetencionAnonimizar, modoRetencionEliminar)
+	}
+
+	return cfg, nil
+}
+
+func sobrescribir(destino *string, valor string) {
+	if valor != "" {
+		*destino = valor
+	}
+}
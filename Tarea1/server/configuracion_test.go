@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfiguracionPrioridadDeCredenciales(t *testing.T) {
+	archivo := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(archivo, []byte(`{"clientId": "archivo-id", "clientSecret": "archivo-secreto", "clientIdFile": "/secretos/archivo"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", archivo)
+	t.Setenv("CLIENT_ID", "entorno-id")
+	t.Setenv("SECRECT_ID", "")
+	t.Setenv("CLIENT_ID_FILE", "")
+	t.Setenv("SECRECT_ID_FILE", "/secretos/entorno")
+
+	cfg, err := cargarConfiguracion([]string{"-client-secret", "flag-secreto", "-client-id-file", "/secretos/flag"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	casos := []struct {
+		nombre, obtenido, esperado string
+	}{
+		{"ClientID", cfg.ClientID, "entorno-id"},
+		{"ClientSecret", cfg.ClientSecret, "flag-secreto"},
+		{"ArchivoClientID", cfg.ArchivoClientID, "/secretos/flag"},
+		{"ArchivoClientSecret", cfg.ArchivoClientSecret, "/secretos/entorno"},
+	}
+	for _, caso := range casos {
+		if caso.obtenido != caso.esperado {
+			t.Errorf("%s = %q, se esperaba %q", caso.nombre, caso.obtenido, caso.esperado)
+		}
+	}
+}
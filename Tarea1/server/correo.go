@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"mime"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Mensaje de correo a enviar a un pasajero
+type Correo struct {
+	Para   string
+	Asunto string
+	Cuerpo string
+}
+
+// Mailer envia correos. Existen dos implementaciones: SMTP y archivo/log
+type Mailer interface {
+	Enviar(ctx context.Context, correo Correo) error
+}
+
+// Envia correos a traves de un servidor SMTP. Sin usuario se conecta sin
+// autenticacion, lo que permite probarlo contra un receptor local (por
+// ejemplo MailHog en localhost:1025)
+type MailerSMTP struct {
+	Host      string
+	Puerto    string
+	Usuario   string
+	Clave     string
+	Remitente string
+}
+
+func (m MailerSMTP) Enviar(ctx context.Context, correo Correo) error {
+	var auth smtp.Auth
+	if m.Usuario != "" {
+		auth = smtp.PlainAuth("", m.Usuario, m.Clave, m.Host)
+	}
+
+	mensaje := construirMensaje(m.Remitente, correo, time.Now())
+
+	// net/smtp no recibe contexto, por lo que el envio se espera en paralelo
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- smtp.SendMail(m.Host+":"+m.Puerto, auth, m.Remitente, []string{correo.Para}, mensaje)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Escribe los correos en un archivo. Util en desarrollo; si no se indica
+// archivo solo se registra el asunto, ya que el destinatario y el cuerpo
+// son datos de los pasajeros
+type MailerArchivo struct {
+	Ruta string
+	mu   sync.Mutex
+}
+
+func (m *MailerArchivo) Enviar(ctx context.Context, correo Correo) error {
+	mensaje := construirMensaje("goTravel", correo, time.Now())
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Ruta == "" {
+		slog.InfoContext(ctx, "Correo enviado", "asunto", correo.Asunto)
+		return nil
+	}
+
+	archivo, err := os.OpenFile(m.Ruta, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer archivo.Close()
+
+	_, err = archivo.Write(append(mensaje, "\r\n"...))
+	return err
+}
+
+// Valor de Configuracion.Mailer para enviar por SMTP
+const mailerSMTP = "smtp"
+
+// Crea el mailer segun la configuracion (MAILER smtp o archivo)
+func crearMailer(cfg Configuracion) Mailer {
+	switch cfg.Mailer {
+	case mailerSMTP:
+		return MailerSMTP{
+			Host:      cfg.SMTPHost,
+			Puerto:    cfg.SMTPPuerto,
+			Usuario:   cfg.SMTPUsuario,
+			Clave:     cfg.SMTPClave,
+			Remitente: cfg.SMTPRemitente,
+		}
+	default:
+		return &MailerArchivo{Ruta: cfg.ArchivoCorreos}
+	}
+}
+
+func construirMensaje(remitente string, correo Correo, fecha time.Time) []byte {
+	var b bytes.Buffer
+	b.WriteString("From: " + remitente + "\r\n")
+	b.WriteString("To: " + correo.Para + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", correo.Asunto) + "\r\n")
+	b.WriteString("Date: " + fecha.Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(correo.Cuerpo, "\n", "\r\n"))
+	return b.Bytes()
+}
+
+var plantillaConfirmacion = template.Must(template.New("confirmacion").Parse(`Hola {{.Nombre}},
+
+Tu reserva en goTravel fue confirmada.
+
+Código de reserva: {{.Referencia}}
+ID de reserva: {{.Id}}
+
+Vuelos:
+{{range .Segmentos}}- {{.Vuelo}}: {{.Origen}} -> {{.Destino}}
+  Salida: {{.Salida}}  Llegada: {{.Llegada}}
+{{end}}
+{{range .Tarifas}}Total pagado: {{.GrandTotal}} {{.Moneda}}
+{{end}}
+¡Buen viaje!
+goTravel
+`))
+
+const (
+	intentosCorreo     = 3
+	esperaEntreCorreos = 5 * time.Second
+)
+
+// Envia la confirmacion de la reserva a cada pasajero con correo. Se ejecuta
+// en segundo plano para no retrasar la respuesta HTTP y reintenta los envios
+// fallidos
+func enviarConfirmaciones(m Mailer, reserva Booking) {
+	recibo := construirRecibo(reserva)
+
+	for _, traveler := range reserva.Data.Travelers {
+		if traveler.Contact.EmailAddress == "" {
+			continue
+		}
+
+		datos := struct {
+			Recibo
+			Nombre string
+		}{recibo, traveler.Name.FirstName}
+
+		var cuerpo bytes.Buffer
+		if err := plantillaConfirmacion.Execute(&cuerpo, datos); err != nil {
+			// Los demas pasajeros reciben su correo igual
+			slog.Error("Error al generar el correo de confirmación", "pasajero", traveler.Id, "error", err)
+			continue
+		}
+
+		correo := Correo{
+			Para:   traveler.Contact.EmailAddress,
+			Asunto: "Confirmación de reserva " + recibo.Referencia,
+			Cuerpo: cuerpo.String(),
+		}
+
+		for intento := 1; intento <= intentosCorreo; intento++ {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			err := m.Enviar(ctx, correo)
+			cancel()
+			if err == nil {
+				break
+			}
+			slog.Error("Error al enviar correo", "asunto", correo.Asunto, "intento", intento, "intentos", intentosCorreo, "error", err)
+			if intento < intentosCorreo {
+				time.Sleep(esperaEntreCorreos * time.Duration(intento))
+			}
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Mensaje recibido por el receptor SMTP de prueba
+type mensajeSMTP struct {
+	remitente    string
+	destinatario string
+	datos        string
+}
+
+// Levanta un receptor SMTP minimo en un puerto libre. Acepta una conexion y
+// entrega el mensaje recibido por el canal
+func receptorSMTP(t *testing.T) (string, string, <-chan mensajeSMTP) {
+	t.Helper()
+	escucha, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { escucha.Close() })
+
+	recibidos := make(chan mensajeSMTP, 1)
+	go func() {
+		conexion, err := escucha.Accept()
+		if err != nil {
+			return
+		}
+		defer conexion.Close()
+
+		lector := bufio.NewReader(conexion)
+		responder := func(linea string) { conexion.Write([]byte(linea + "\r\n")) }
+		var mensaje mensajeSMTP
+
+		responder("220 localhost ESMTP prueba")
+		for {
+			linea, err := lector.ReadString('\n')
+			if err != nil {
+				return
+			}
+			comando := strings.TrimRight(linea, "\r\n")
+			switch {
+			case strings.HasPrefix(comando, "EHLO"), strings.HasPrefix(comando, "HELO"):
+				responder("250 localhost")
+			case strings.HasPrefix(comando, "MAIL FROM:"):
+				mensaje.remitente = strings.Trim(strings.TrimPrefix(comando, "MAIL FROM:"), "<>")
+				responder("250 OK")
+			case strings.HasPrefix(comando, "RCPT TO:"):
+				mensaje.destinatario = strings.Trim(strings.TrimPrefix(comando, "RCPT TO:"), "<>")
+				responder("250 OK")
+			case comando == "DATA":
+				responder("354 Fin con <CRLF>.<CRLF>")
+				var datos strings.Builder
+				for {
+					linea, err := lector.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if linea == ".\r\n" {
+						break
+					}
+					datos.WriteString(linea)
+				}
+				mensaje.datos = datos.String()
+				responder("250 OK")
+				recibidos <- mensaje
+			case comando == "QUIT":
+				responder("221 Adios")
+				return
+			default:
+				responder("250 OK")
+			}
+		}
+	}()
+
+	host, puerto, _ := net.SplitHostPort(escucha.Addr().String())
+	return host, puerto, recibidos
+}
+
+func TestMailerSMTPEnviaElCorreo(t *testing.T) {
+	host, puerto, recibidos := receptorSMTP(t)
+	cfg := configuracionPorDefecto()
+	cfg.Mailer = mailerSMTP
+	cfg.SMTPHost = host
+	cfg.SMTPPuerto = puerto
+	cfg.SMTPRemitente = "reservas@gotravel.test"
+
+	mailer := crearMailer(cfg)
+	if _, ok := mailer.(MailerSMTP); !ok {
+		t.Fatalf("crearMailer devolvió %T, se esperaba MailerSMTP", mailer)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	correo := Correo{Para: "ana@pasajero.test", Asunto: "Confirmación de reserva ABC123", Cuerpo: "Hola Ana,\nTu reserva fue confirmada."}
+	if err := mailer.Enviar(ctx, correo); err != nil {
+		t.Fatalf("Enviar: %v", err)
+	}
+
+	select {
+	case mensaje := <-recibidos:
+		if mensaje.remitente != cfg.SMTPRemitente || mensaje.destinatario != correo.Para {
+			t.Errorf("sobre = %q -> %q", mensaje.remitente, mensaje.destinatario)
+		}
+		for _, esperado := range []string{
+			"To: ana@pasajero.test\r\n",
+			"Subject: =?utf-8?q?Confirmaci=C3=B3n_de_reserva_ABC123?=\r\n",
+			"Hola Ana,\r\nTu reserva fue confirmada.",
+		} {
+			if !strings.Contains(mensaje.datos, esperado) {
+				t.Errorf("el mensaje no contiene %q:\n%s", esperado, mensaje.datos)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("el receptor SMTP no recibió el correo")
+	}
+}
+
+func TestCrearMailerSinSMTPUsaArchivo(t *testing.T) {
+	cfg := configuracionPorDefecto()
+	cfg.ArchivoCorreos = "correos.txt"
+
+	mailer, ok := crearMailer(cfg).(*MailerArchivo)
+	if !ok || mailer.Ruta != "correos.txt" {
+		t.Fatalf("crearMailer = %#v, se esperaba MailerArchivo con la ruta configurada", mailer)
+	}
+}
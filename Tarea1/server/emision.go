@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Cambio registrado cuando una reserva sin boletos se acerca a su ultimo
+// dia de emision
+const cambioPlazoEmision = "PLAZO_EMISION"
+
+// Cada cuanto se revisan los plazos de emision de las reservas guardadas
+const intervaloPlazoEmision = time.Hour
+
+// Devuelve un error si alguna oferta ya paso su ultimo dia de emision
+// (lastTicketingDate). Ese dia todavia se puede emitir
+func validarPlazoEmision(ofertas []FlightOffer, ahora time.Time) error {
+	hoy := ahora.Format(formatoFecha)
+	for _, oferta := range ofertas {
+		if oferta.LastTicketingDate != "" && oferta.LastTicketingDate < hoy {
+			return fmt.Errorf("la oferta %s venció: el último día para emitir era %s", oferta.Id, oferta.LastTicketingDate)
+		}
+	}
+	return nil
+}
+
+// Revisa cada intervaloPlazoEmision las reservas guardadas, hasta que se
+// cancele ctx
+func iniciarAvisosEmision(ctx context.Context) {
+	if config.AvisoEmision <= 0 {
+		return
+	}
+
+	enSegundoPlano(func() {
+		ticker := time.NewTicker(intervaloPlazoEmision)
+		defer ticker.Stop()
+		for {
+			if _, err := paraCadaAgencia(ctx, func(ctx context.Context) (int, error) { return avisarPlazosEmision(ctx, time.Now()) }); err != nil {
+				slog.ErrorContext(ctx, "Error al revisar los plazos de emisión", "error", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+// Marca para revision las reservas activas sin boletos cuyo ultimo dia de
+// emision cae dentro de config.AvisoEmision. Se omiten las anonimizadas y
+// las que ya no tienen vuelos por salir. Cada reserva se avisa una vez
+func avisarPlazosEmision(ctx context.Context, ahora time.Time) (int, error) {
+	clientDB, err := conectarMongo(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer clientDB.Disconnect(context.TODO())
+
+	limite := ahora.Add(config.AvisoEmision).Format(formatoFecha)
+	filtro := bson.M{
+		"cancelada":                           bson.M{"$ne": true},
+		"anonimizada":                         bson.M{"$ne": true},
+		"avisoEmision":                        bson.M{"$ne": true},
+		"data.tickets.0":                      bson.M{"$exists": false},
+		"data.flightoffers.lastticketingdate": bson.M{"$gt": "", "$lte": limite},
+		"data.flightoffers.itineraries.segments.departure.at": bson.M{"$gte": ahora.Format(formatoAmadeus)},
+	}
+	collection := clientDB.Database(baseDeDatos).Collection(coleccionReservasDe(ctx))
+	cursor, err := collection.Find(ctx, filtro)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	avisadas := 0
+	for cursor.Next(ctx) {
+		var documento struct {
+			Id      interface{} `bson:"_id"`
+			Booking `bson:",inline"`
+		}
+		if err := cursor.Decode(&documento); err != nil {
+			return avisadas, err
+		}
+
+		plazo := ""
+		for _, oferta := range documento.Data.FlightOffers {
+			if oferta.LastTicketingDate != "" && (plazo == "" || oferta.LastTicketingDate < plazo) {
+				plazo = oferta.LastTicketingDate
+			}
+		}
+		detalle := fmt.Sprintf("La reserva no tiene boletos y el último día para emitir es %s", plazo)
+		if plazo < ahora.Format(formatoFecha) {
+			detalle = fmt.Sprintf("La reserva no tiene boletos y el plazo de emisión venció el %s", plazo)
+		}
+		slog.WarnContext(ctx, "Aviso de emisión", "reserva", documento.Data.Id, "detalle", detalle)
+
+		actualizacion := bson.M{
+			"$set":  bson.M{"avisoEmision": true, "requiereRevision": true},
+			"$push": bson.M{"historial": CambioReserva{Fecha: ahora.UTC(), Tipo: cambioPlazoEmision, Detalle: detalle}},
+		}
+		if _, err := collection.UpdateOne(ctx, bson.M{"_id": documento.Id}, actualizacion); err != nil {
+			return avisadas, err
+		}
+		auditarReserva(ctx, cambioPlazoEmision, documento.Data.Id, 0)
+		avisadas++
+	}
+	return avisadas, cursor.Err()
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAvisarPlazosEmisionOmiteAnonimizadasYVuelosPasados(t *testing.T) {
+	anterior := config
+	t.Cleanup(func() { config = anterior })
+	config = configuracionPorDefecto()
+	mongo := nuevoMongoFalso(t)
+
+	ahora := time.Date(2030, 3, 1, 12, 0, 0, 0, time.UTC)
+	if _, err := avisarPlazosEmision(context.Background(), ahora); err != nil {
+		t.Fatal(err)
+	}
+
+	consultas := mongo.consultas(coleccionReservas)
+	if len(consultas) != 1 {
+		t.Fatalf("consultas = %d, se esperaba una", len(consultas))
+	}
+	filtro := consultas[0]
+	if anonimizada, err := filtro.LookupErr("anonimizada", "$ne"); err != nil || !anonimizada.Boolean() {
+		t.Errorf("el filtro no excluye las reservas anonimizadas: %v", filtro)
+	}
+	salida, err := filtro.LookupErr("data.flightoffers.itineraries.segments.departure.at", "$gte")
+	if err != nil || salida.StringValue() != ahora.Format(formatoAmadeus) {
+		t.Errorf("el filtro no exige vuelos por salir: %v", filtro)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Emisiones estimadas de CO2 de una oferta. Son una aproximacion a partir
+// de la distancia entre aeropuertos, el avion y la cabina de cada pasajero
+type EmisionesCO2 struct {
+	Kilogramos  float64 `json:"kg" bson:"kg"`
+	PorPasajero float64 `json:"kgPerPassenger" bson:"kgPerPassenger"`
+	DistanciaKm float64 `json:"distanceKm" bson:"distanceKm"`
+}
+
+// Criterios de orden aceptados por /search
+const (
+	ordenPrecio    = "price"
+	ordenEmisiones = "co2"
+)
+
+const radioTierraKm = 6371.0
+
+// Las rutas reales son mas largas que el circulo maximo (esperas, desvios)
+const recargoDistancia = 1.08
+
+// Kg de CO2 por pasajero y km en clase economica, segun el largo del tramo.
+// Los despegues pesan mas en los vuelos cortos
+func factorDistancia(km float64) float64 {
+	switch {
+	case km < 500:
+		return 0.25
+	case km < 3700:
+		return 0.15
+	}
+	return 0.11
+}
+
+// Espacio que ocupa cada cabina respecto de la economica
+var factoresCabina = map[string]float64{
+	"ECONOMY":         1,
+	"PREMIUM_ECONOMY": 1.6,
+	"BUSINESS":        2.9,
+	"FIRST":           4,
+}
+
+// Aviones (codigo IATA que entrega Amadeus) con consumo distinto al
+// promedio. El resto usa factor 1
+var factoresAvion = map[string]float64{
+	// Nueva generacion
+	"32N": 0.85, "32Q": 0.85, "31N": 0.85, "7M8": 0.85, "7M9": 0.85,
+	"788": 0.85, "789": 0.85, "78J": 0.85, "359": 0.85, "351": 0.85,
+	"E95": 0.9, "E75": 0.9, "AT7": 0.9, "AT5": 0.9,
+	// Cuatrimotores y modelos antiguos
+	"744": 1.25, "343": 1.2, "346": 1.25, "380": 1.15, "762": 1.15, "763": 1.1,
+}
+
+// Distancia de circulo maximo entre dos aeropuertos
+func distanciaKm(origen, destino Aeropuerto) float64 {
+	lat1 := origen.Latitud * math.Pi / 180
+	lat2 := destino.Latitud * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (destino.Longitud - origen.Longitud) * math.Pi / 180
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * radioTierraKm * math.Asin(math.Sqrt(a))
+}
+
+// Estima las emisiones de una oferta sumando cada segmento por cada
+// pasajero con asiento. Devuelve nil si algun aeropuerto no esta en la
+// lista incluida
+func estimarEmisiones(oferta FlightOffer) *EmisionesCO2 {
+	// Cabina de cada pasajero por segmento
+	cabinas := make(map[string][]string)
+	pasajeros := 0
+	for _, pricing := range oferta.TravelerPricings {
+		// Los infantes en brazos no ocupan asiento
+		if pricing.TravelerType == "HELD_INFANT" {
+			continue
+		}
+		pasajeros++
+		for _, detalle := range pricing.FareDetailsBySegment {
+			cabinas[detalle.SegmentId] = append(cabinas[detalle.SegmentId], detalle.Cabin)
+		}
+	}
+	if pasajeros == 0 {
+		return nil
+	}
+
+	var emisiones EmisionesCO2
+	for _, itinerario := range oferta.Itineraries {
+		for _, segmento := range itinerario.Segments {
+			origen, ok := buscarAeropuerto(segmento.Departure.IataCode)
+			if !ok {
+				return nil
+			}
+			destino, ok := buscarAeropuerto(segmento.Arrival.IataCode)
+			if !ok {
+				return nil
+			}
+
+			km := distanciaKm(origen, destino)
+			emisiones.DistanciaKm += km
+			porAsiento := km * recargoDistancia * factorDistancia(km)
+			if factor, ok := factoresAvion[segmento.Aircraft.Code]; ok {
+				porAsiento *= factor
+			}
+
+			// Si la oferta no informa la cabina del segmento se asume economica
+			asientos := cabinas[segmento.Id]
+			for len(asientos) < pasajeros {
+				asientos = append(asientos, "ECONOMY")
+			}
+			for _, cabina := range asientos {
+				factor, ok := factoresCabina[cabina]
+				if !ok {
+					factor = 1
+				}
+				emisiones.Kilogramos += porAsiento * factor
+			}
+		}
+	}
+
+	emisiones.PorPasajero = redondear(emisiones.Kilogramos / float64(pasajeros))
+	emisiones.Kilogramos = redondear(emisiones.Kilogramos)
+	emisiones.DistanciaKm = math.Round(emisiones.DistanciaKm)
+	return &emisiones
+}
+
+func redondear(valor float64) float64 {
+	return math.Round(valor*10) / 10
+}
+
+// Agrega la estimacion de emisiones a cada oferta
+func agregarEmisiones(ofertas []FlightOffer) {
+	for i := range ofertas {
+		ofertas[i].Emisiones = estimarEmisiones(ofertas[i])
+	}
+}
+
+func validarOrden(criterio string) error {
+	switch criterio {
+	case "", ordenPrecio, ordenEmisiones:
+		return nil
+	}
+	return fmt.Errorf("sort debe ser %s o %s", ordenPrecio, ordenEmisiones)
+}
+
+// Ordena las ofertas por precio o por emisiones. Las ofertas sin estimacion
+// quedan al final; sin criterio se conserva el orden de los proveedores
+func ordenarOfertas(ofertas []FlightOffer, criterio string) {
+	switch criterio {
+	case ordenPrecio:
+		sort.SliceStable(ofertas, func(i, j int) bool {
+			return precioOferta(ofertas[i]) < precioOferta(ofertas[j])
+		})
+	case ordenEmisiones:
+		sort.SliceStable(ofertas, func(i, j int) bool {
+			return kilogramosOferta(ofertas[i]) < kilogramosOferta(ofertas[j])
+		})
+	}
+}
+
+func kilogramosOferta(oferta FlightOffer) float64 {
+	if oferta.Emisiones == nil {
+		return math.MaxFloat64
+	}
+	return oferta.Emisiones.Kilogramos
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Eventos enviados por las busquedas en vivo
+const (
+	eventoOfertas     = "offers"
+	eventoAdvertencia = "warning"
+	eventoCelda       = "cell"
+	eventoResumen     = "summary"
+	eventoError       = "error"
+)
+
+// Ofertas de un proveedor, enviadas apenas responde. Pueden repetir
+// itinerarios de otros proveedores; el resumen final trae la lista combinada
+type OfertasProveedor struct {
+	Proveedor string        `json:"provider"`
+	Ofertas   []FlightOffer `json:"data"`
+}
+
+// Igual que /search, pero responde con Server-Sent Events: un evento offers
+// por cada proveedor que responde, un warning por cada proveedor que falla
+// y un summary final con el mismo contenido que /search
+func buscarVuelosEnVivo(c *gin.Context) {
+	parametros := ParametrosBusqueda{
+		Origen:       c.Query("originLocationCode"),
+		Destino:      c.Query("destinationLocationCode"),
+		FechaSalida:  c.Query("departureDate"),
+		FechaRegreso: c.Query("returnDate"),
+		Adultos:      c.Query("adults"),
+		Ninos:        c.Query("children"),
+		Infantes:     c.Query("infants"),
+		Mayores:      c.Query("seniors"),
+	}
+	if err := parametros.validar(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	orden := c.Query("sort")
+	if err := validarOrden(orden); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	iniciarEventos(c)
+	resultado, err := buscarEnProveedores(c.Request.Context(), parametros, func(proveedor string, ofertas []FlightOffer, err error) {
+		if err != nil {
+			enviarEvento(c, eventoAdvertencia, gin.H{"provider": proveedor, "error": err.Error()})
+			return
+		}
+		enviarEvento(c, eventoOfertas, OfertasProveedor{Proveedor: proveedor, Ofertas: ofertas})
+	})
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error al buscar vuelos", "error", err)
+		enviarEvento(c, eventoError, gin.H{"error": "Error al buscar vuelos", "warnings": resultado.Advertencias})
+		return
+	}
+	ordenarOfertas(resultado.Ofertas, orden)
+	enviarEvento(c, eventoResumen, resultado)
+}
+
+// Igual que /search/calendar, pero envia un evento cell por cada fecha a
+// medida que se completa y un summary final con el calendario completo
+func buscarFechasFlexiblesEnVivo(c *gin.Context) {
+	calendario, busquedas, ok := leerCalendario(c)
+	if !ok {
+		return
+	}
+
+	iniciarEventos(c)
+	llenarCalendario(c.Request.Context(), &calendario, busquedas, func(celda CeldaCalendario) {
+		enviarEvento(c, eventoCelda, celda)
+	})
+	enviarEvento(c, eventoResumen, calendario)
+}
+
+func iniciarEventos(c *gin.Context) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// Evita que un proxy intermedio acumule los eventos
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+}
+
+// Envia un evento y lo despacha de inmediato al cliente. Si el cliente ya
+// se desconecto el evento se descarta
+func enviarEvento(c *gin.Context, evento string, datos interface{}) {
+	if c.Request.Context().Err() != nil {
+		return
+	}
+	c.SSEvent(evento, datos)
+	c.Writer.Flush()
+}
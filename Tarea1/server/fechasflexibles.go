@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	formatoFecha = "2006-01-02"
+
+	// Dias por defecto y maximos hacia cada lado de la fecha pedida
+	ventanaPorDefecto = 3
+	ventanaMaxima     = 7
+
+	// Busquedas simultaneas a Amadeus al armar el calendario
+	busquedasSimultaneas = 4
+)
+
+// Oferta mas barata para una combinacion de fechas. Sin oferta, Precio
+// queda vacio
+type CeldaCalendario struct {
+	FechaSalida  string       `json:"departureDate"`
+	FechaRegreso string       `json:"returnDate,omitempty"`
+	Precio       string       `json:"price,omitempty"`
+	Moneda       string       `json:"currency,omitempty"`
+	Oferta       *FlightOffer `json:"offer,omitempty"`
+	Error        string       `json:"error,omitempty"`
+}
+
+type CalendarioPrecios struct {
+	FechasSalida  []string          `json:"departureDates"`
+	FechasRegreso []string          `json:"returnDates,omitempty"`
+	Celdas        []CeldaCalendario `json:"cells"`
+}
+
+// Busca la oferta mas barata para cada fecha (o par de fechas, si se indica
+// returnDate) dentro de una ventana de +-window dias
+func buscarFechasFlexibles(c *gin.Context) {
+	calendario, busquedas, ok := leerCalendario(c)
+	if !ok {
+		return
+	}
+
+	llenarCalendario(c.Request.Context(), &calendario, busquedas, nil)
+	c.JSON(http.StatusOK, calendario)
+}
+
+// Lee los parametros del calendario y arma las busquedas a realizar. Si
+// algun parametro no es valido responde 400 y devuelve false
+func leerCalendario(c *gin.Context) (CalendarioPrecios, []ParametrosBusqueda, bool) {
+	base := ParametrosBusqueda{
+		Origen:   c.Query("originLocationCode"),
+		Destino:  c.Query("destinationLocationCode"),
+		Adultos:  c.Query("adults"),
+		Ninos:    c.Query("children"),
+		Infantes: c.Query("infants"),
+		Mayores:  c.Query("seniors"),
+	}
+	if err := base.validar(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return CalendarioPrecios{}, nil, false
+	}
+
+	ventana := ventanaPorDefecto
+	if valor := c.Query("window"); valor != "" {
+		n, err := strconv.Atoi(valor)
+		if err != nil || n < 0 || n > ventanaMaxima {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("window debe estar entre 0 y %d", ventanaMaxima)})
+			return CalendarioPrecios{}, nil, false
+		}
+		ventana = n
+	}
+
+	salida, err := time.Parse(formatoFecha, c.Query("departureDate"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "departureDate no válida (AAAA-MM-DD)"})
+		return CalendarioPrecios{}, nil, false
+	}
+
+	calendario := CalendarioPrecios{FechasSalida: rangoFechas(salida, ventana)}
+	if valor := c.Query("returnDate"); valor != "" {
+		regreso, err := time.Parse(formatoFecha, valor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "returnDate no válida (AAAA-MM-DD)"})
+			return CalendarioPrecios{}, nil, false
+		}
+		calendario.FechasRegreso = rangoFechas(regreso, ventana)
+	}
+
+	// Combinaciones de fechas a buscar. El regreso no puede ser anterior a
+	// la salida
+	var busquedas []ParametrosBusqueda
+	for _, fechaSalida := range calendario.FechasSalida {
+		if len(calendario.FechasRegreso) == 0 {
+			p := base
+			p.FechaSalida = fechaSalida
+			busquedas = append(busquedas, p)
+			continue
+		}
+		for _, fechaRegreso := range calendario.FechasRegreso {
+			if fechaRegreso < fechaSalida {
+				continue
+			}
+			p := base
+			p.FechaSalida = fechaSalida
+			p.FechaRegreso = fechaRegreso
+			busquedas = append(busquedas, p)
+		}
+	}
+	return calendario, busquedas, true
+}
+
+// Realiza las busquedas del calendario de a busquedasSimultaneas. Si se
+// indica avisar, se llama (desde el goroutine que invoca) con cada celda
+// apenas se completa
+func llenarCalendario(ctx context.Context, calendario *CalendarioPrecios, busquedas []ParametrosBusqueda, avisar func(CeldaCalendario)) {
+	type resultado struct {
+		indice int
+		celda  CeldaCalendario
+	}
+	resultados := make(chan resultado, len(busquedas))
+	semaforo := make(chan struct{}, busquedasSimultaneas)
+	for i, parametros := range busquedas {
+		go func(i int, parametros ParametrosBusqueda) {
+			semaforo <- struct{}{}
+			defer func() { <-semaforo }()
+
+			celda := CeldaCalendario{FechaSalida: parametros.FechaSalida, FechaRegreso: parametros.FechaRegreso}
+			ofertas, err := buscarEnProveedores(ctx, parametros, nil)
+			if err != nil {
+				celda.Error = err.Error()
+			} else if oferta, ok := ofertaMasBarata(ofertas.Ofertas); ok {
+				celda.Oferta = &oferta
+				celda.Precio = oferta.Price.GrandTotal
+				celda.Moneda = oferta.Price.Currency
+			}
+			resultados <- resultado{i, celda}
+		}(i, parametros)
+	}
+
+	calendario.Celdas = make([]CeldaCalendario, len(busquedas))
+	for range busquedas {
+		r := <-resultados
+		calendario.Celdas[r.indice] = r.celda
+		if avisar != nil {
+			avisar(r.celda)
+		}
+	}
+}
+
+// Fechas desde fecha-ventana hasta fecha+ventana, sin incluir dias pasados
+func rangoFechas(fecha time.Time, ventana int) []string {
+	hoy := time.Now().Truncate(24 * time.Hour)
+	var fechas []string
+	for d := -ventana; d <= ventana; d++ {
+		dia := fecha.AddDate(0, 0, d)
+		if dia.Before(hoy) {
+			continue
+		}
+		fechas = append(fechas, dia.Format(formatoFecha))
+	}
+	return fechas
+}
+
+func ofertaMasBarata(ofertas []FlightOffer) (FlightOffer, bool) {
+	var validas []FlightOffer
+	for _, oferta := range ofertas {
+		if _, err := strconv.ParseFloat(oferta.Price.GrandTotal, 64); err == nil {
+			validas = append(validas, oferta)
+		}
+	}
+	if len(validas) == 0 {
+		return FlightOffer{}, false
+	}
+
+	sort.SliceStable(validas, func(i, j int) bool {
+		a, _ := strconv.ParseFloat(validas[i].Price.GrandTotal, 64)
+		b, _ := strconv.ParseFloat(validas[j].Price.GrandTotal, 64)
+		return a < b
+	})
+	return validas[0], true
+}
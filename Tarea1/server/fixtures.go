@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Modos de acceso al proveedor. En modo grabar cada llamada a Amadeus se
+// guarda en un archivo del directorio de fixtures; en modo reproducir las
+// llamadas se responden desde esos archivos sin acceder a la red
+const (
+	modoProveedorGrabar     = "record"
+	modoProveedorReproducir = "replay"
+)
+
+// Ruta del token de Amadeus. Su cuerpo lleva las credenciales, por lo que
+// no se guarda ni se usa para identificar la llamada
+const rutaToken = "/v1/security/oauth2/token"
+
+// Respuesta que se guarda en lugar de la del token, para que las fixtures
+// no lleven un token de acceso valido
+const tokenFixture = `{"type":"amadeusOAuth2Token","token_type":"Bearer","access_token":"fixture","expires_in":1799,"state":"approved"}`
+
+// Llamada al proveedor guardada en un archivo de fixture
+type Fixture struct {
+	Solicitud struct {
+		Metodo string          `json:"method"`
+		URL    string          `json:"url"`
+		Cuerpo json.RawMessage `json:"body,omitempty"`
+	} `json:"request"`
+	Respuesta struct {
+		Estado int             `json:"status"`
+		Tipo   string          `json:"contentType,omitempty"`
+		Cuerpo json.RawMessage `json:"body,omitempty"`
+	} `json:"response"`
+}
+
+// Crea el cliente HTTP para Amadeus sobre el transporte indicado, con las
+// trazas, la auditoria, las metricas y el registro de cada llamada
+func nuevoClienteAmadeus(base http.RoundTripper) *http.Client {
+	return &http.Client{Transport: otelhttp.NewTransport(transporteAuditado{base: transporteMedido{base: transporteRegistrado{base: base}}})}
+}
+
+// Configura el cliente de Amadeus segun el modo indicado ("", record,
+// replay o fake-seatmaps). Los tests de handlers pueden llamarla con
+// replay para no depender de la red
+func usarModoProveedor(modo, directorio string) error {
+	switch modo {
+	case "", modoAsientosSimulados:
+		clienteAmadeus = nuevoClienteAmadeus(http.DefaultTransport)
+	case modoProveedorGrabar:
+		if err := os.MkdirAll(directorio, 0o755); err != nil {
+			return fmt.Errorf("no se pudo crear el directorio de fixtures: %w", err)
+		}
+		clienteAmadeus = nuevoClienteAmadeus(&transporteFixtures{base: http.DefaultTransport, directorio: directorio, grabar: true})
+	case modoProveedorReproducir:
+		if _, err := os.Stat(directorio); err != nil {
+			return fmt.Errorf("no se encontró el directorio de fixtures: %w", err)
+		}
+		clienteAmadeus = nuevoClienteAmadeus(&transporteFixtures{directorio: directorio})
+	default:
+		return fmt.Errorf("modo de proveedor no válido: %q (use %s, %s o %s)", modo, modoProveedorGrabar, modoProveedorReproducir, modoAsientosSimulados)
+	}
+	return nil
+}
+
+// Transporte que graba o reproduce las llamadas al proveedor. Cada llamada
+// se identifica por metodo, ruta, query y cuerpo; si la misma llamada se
+// repite, las respuestas se guardan y se reproducen en orden (la ultima se
+// repite cuando se acaban)
+type transporteFixtures struct {
+	base       http.RoundTripper
+	directorio string
+	grabar     bool
+
+	mu         sync.Mutex
+	contadores map[string]int
+}
+
+func (t *transporteFixtures) RoundTrip(req *http.Request) (*http.Response, error) {
+	var cuerpo []byte
+	if req.Body != nil {
+		var err error
+		cuerpo, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(cuerpo))
+	}
+	if req.URL.Path == rutaToken {
+		cuerpo = nil
+	}
+
+	clave := claveFixture(req, cuerpo)
+	t.mu.Lock()
+	if t.contadores == nil {
+		t.contadores = make(map[string]int)
+	}
+	t.contadores[clave]++
+	numero := t.contadores[clave]
+	t.mu.Unlock()
+
+	if t.grabar {
+		return t.grabarLlamada(req, cuerpo, clave, numero)
+	}
+	return t.reproducirLlamada(req, clave, numero)
+}
+
+func (t *transporteFixtures) grabarLlamada(req *http.Request, cuerpo []byte, clave string, numero int) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respuesta, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respuesta))
+
+	var fixture Fixture
+	fixture.Solicitud.Metodo = req.Method
+	fixture.Solicitud.URL = req.URL.RequestURI()
+	// El cuerpo de la solicitud se guarda solo como referencia, sin datos
+	// personales; el hash de la clave se calcula con el original
+	fixture.Solicitud.Cuerpo = comoJSON([]byte(sanitizarSolicitud(req.Header.Get("Content-Type"), cuerpo)))
+	fixture.Respuesta.Estado = resp.StatusCode
+	fixture.Respuesta.Tipo = resp.Header.Get("Content-Type")
+	// La respuesta se reproduce tal como se guarda, sin el token ni los
+	// datos personales de los pasajeros
+	fixture.Respuesta.Cuerpo = comoJSON(sanitizarRespuesta(req.URL.Path, respuesta))
+
+	var contenido bytes.Buffer
+	codificador := json.NewEncoder(&contenido)
+	codificador.SetEscapeHTML(false)
+	codificador.SetIndent("", "  ")
+	err = codificador.Encode(fixture)
+	if err == nil {
+		err = os.WriteFile(t.archivo(clave, numero), contenido.Bytes(), 0o644)
+	}
+	if err != nil {
+		slog.Error("Error al grabar la fixture", "error", err)
+	}
+	return resp, nil
+}
+
+func (t *transporteFixtures) reproducirLlamada(req *http.Request, clave string, numero int) (*http.Response, error) {
+	// Si la llamada se repite mas veces que las grabadas, se usa la ultima
+	var contenido []byte
+	var err error
+	for ; numero >= 1; numero-- {
+		contenido, err = os.ReadFile(t.archivo(clave, numero))
+		if err == nil || !os.IsNotExist(err) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("no hay fixture para %s %s (%s): %w", req.Method, req.URL.RequestURI(), clave, err)
+	}
+
+	var fixture Fixture
+	if err := json.Unmarshal(contenido, &fixture); err != nil {
+		return nil, fmt.Errorf("fixture no válida para %s: %w", clave, err)
+	}
+
+	cuerpo := []byte(fixture.Respuesta.Cuerpo)
+	var texto string
+	if json.Unmarshal(cuerpo, &texto) == nil {
+		cuerpo = []byte(texto)
+	}
+	encabezados := make(http.Header)
+	if fixture.Respuesta.Tipo != "" {
+		encabezados.Set("Content-Type", fixture.Respuesta.Tipo)
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(fixture.Respuesta.Estado) + " " + http.StatusText(fixture.Respuesta.Estado),
+		StatusCode:    fixture.Respuesta.Estado,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        encabezados,
+		Body:          io.NopCloser(bytes.NewReader(cuerpo)),
+		ContentLength: int64(len(cuerpo)),
+		Request:       req,
+	}, nil
+}
+
+var caracteresArchivo = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+func (t *transporteFixtures) archivo(clave string, numero int) string {
+	return filepath.Join(t.directorio, fmt.Sprintf("%s-%d.json", clave, numero))
+}
+
+// Nombre base del archivo de una llamada: metodo, ruta y un hash de la
+// query y del cuerpo. Los cuerpos JSON se normalizan para que el orden de
+// los campos no cambie el hash
+func claveFixture(req *http.Request, cuerpo []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(req.URL.Query().Encode()))
+	hash.Write([]byte{0})
+	var valor interface{}
+	if json.Unmarshal(cuerpo, &valor) == nil {
+		normalizado, _ := json.Marshal(valor)
+		hash.Write(normalizado)
+	} else {
+		hash.Write(cuerpo)
+	}
+
+	ruta := caracteresArchivo.ReplaceAllString(strings.Trim(req.URL.Path, "/"), "_")
+	return strings.ToLower(req.Method) + "_" + ruta + "-" + hex.EncodeToString(hash.Sum(nil))[:12]
+}
+
+// Quita de una respuesta del proveedor lo que no debe quedar en una
+// fixture: el token de acceso y los datos personales
+func sanitizarRespuesta(ruta string, cuerpo []byte) []byte {
+	if ruta == rutaToken {
+		return []byte(tokenFixture)
+	}
+	if len(cuerpo) == 0 {
+		return nil
+	}
+	return []byte(redactarJSON(cuerpo))
+}
+
+// Los cuerpos JSON se guardan tal cual para que las fixtures sean legibles;
+// el resto se guarda como texto
+func comoJSON(cuerpo []byte) json.RawMessage {
+	if len(cuerpo) == 0 {
+		return nil
+	}
+	if json.Valid(cuerpo) {
+		return cuerpo
+	}
+	texto, _ := json.Marshal(string(cuerpo))
+	return texto
+}
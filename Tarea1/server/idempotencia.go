@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	coleccionIdempotencia = "idempotencia"
+	cabeceraIdempotencia  = "Idempotency-Key"
+
+	// Tiempo que se conserva una clave antes de que MongoDB la elimine
+	vigenciaIdempotencia = 24 * time.Hour
+)
+
+const (
+	idempotenciaEnProceso  = "en_proceso"
+	idempotenciaCompletada = "completada"
+)
+
+// Respuesta guardada para una clave de idempotencia
+type RegistroIdempotencia struct {
+	Clave      string    `bson:"_id"`
+	Hash       string    `bson:"hash"`
+	Estado     string    `bson:"estado"`
+	Creado     time.Time `bson:"creado"`
+	EstadoHTTP int       `bson:"estadoHttp,omitempty"`
+	TipoCuerpo string    `bson:"tipoCuerpo,omitempty"`
+	Cuerpo     []byte    `bson:"cuerpo,omitempty"`
+}
+
+var indiceIdempotencia sync.Once
+
+// Middleware que hace idempotente una ruta cuando el cliente envia la
+// cabecera Idempotency-Key. La primera solicitud con una clave se procesa y
+// su respuesta queda guardada; las siguientes reciben esa misma respuesta
+// sin volver a ejecutar la ruta. Si la primera aun no termina se responde
+// 409, y si la clave se reutiliza con otro cuerpo se responde 422
+func idempotente() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clave := c.GetHeader(cabeceraIdempotencia)
+		if clave == "" {
+			c.Next()
+			return
+		}
+		// Las claves de cada agencia son independientes
+		if agencia := idAgencia(c.Request.Context()); agencia != "" {
+			clave = agencia + ":" + clave
+		}
+
+		cuerpo, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "No se pudo leer la solicitud"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(cuerpo))
+		suma := sha256.Sum256(cuerpo)
+		hash := hex.EncodeToString(suma[:])
+
+		ctx := c.Request.Context()
+		clientDB, err := conectarMongo(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error al conectar con MongoDB", "error", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Error al conectar con la base de datos"})
+			return
+		}
+		defer clientDB.Disconnect(context.TODO())
+
+		collection := clientDB.Database(baseDeDatos).Collection(coleccionIdempotencia)
+		indiceIdempotencia.Do(func() {
+			indice := mongo.IndexModel{
+				Keys:    bson.D{{Key: "creado", Value: 1}},
+				Options: options.Index().SetExpireAfterSeconds(int32(vigenciaIdempotencia.Seconds())),
+			}
+			if _, err := collection.Indexes().CreateOne(ctx, indice); err != nil {
+				slog.ErrorContext(ctx, "Error al crear el índice de idempotencia", "error", err)
+			}
+		})
+
+		registro := RegistroIdempotencia{
+			Clave:  clave,
+			Hash:   hash,
+			Estado: idempotenciaEnProceso,
+			Creado: time.Now().UTC(),
+		}
+		_, err = collection.InsertOne(ctx, registro)
+		if mongo.IsDuplicateKeyError(err) {
+			responderRepetida(c, collection, clave, hash)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Error al guardar la clave de idempotencia", "error", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Error al guardar la clave de idempotencia"})
+			return
+		}
+
+		escritor := &escritorCapturado{ResponseWriter: c.Writer}
+		c.Writer = escritor
+		c.Next()
+
+		// La ruta pidio conservar la clave en proceso porque no sabe si la
+		// operacion se completo; los reintentos reciben 409
+		if c.GetBool(claveConservada) {
+			slog.WarnContext(ctx, "La clave de idempotencia queda en proceso", "estado", escritor.Status())
+			return
+		}
+
+		// Los errores internos no se guardan para que el cliente pueda
+		// reintentar con la misma clave
+		if escritor.Status() >= http.StatusInternalServerError {
+			if _, err := collection.DeleteOne(context.TODO(), bson.M{"_id": clave}); err != nil {
+				slog.ErrorContext(ctx, "Error al liberar la clave de idempotencia", "error", err)
+			}
+			return
+		}
+
+		actualizacion := bson.M{"$set": bson.M{
+			"estado":     idempotenciaCompletada,
+			"estadoHttp": escritor.Status(),
+			"tipoCuerpo": escritor.Header().Get("Content-Type"),
+			"cuerpo":     escritor.cuerpo.Bytes(),
+		}}
+		if _, err := collection.UpdateOne(context.TODO(), bson.M{"_id": clave}, actualizacion); err != nil {
+			slog.ErrorContext(ctx, "Error al guardar la respuesta idempotente", "error", err)
+		}
+	}
+}
+
+// Clave del contexto de gin que marca la clave de idempotencia como
+// conservada
+const claveConservada = "idempotenciaConservada"
+
+// Deja la clave de idempotencia de la solicitud en proceso aunque la ruta
+// responda con un error, para que un reintento no repita la operacion
+func conservarClaveIdempotencia(c *gin.Context) {
+	c.Set(claveConservada, true)
+}
+
+func responderRepetida(c *gin.Context, collection *mongo.Collection, clave string, hash string) {
+	var registro RegistroIdempotencia
+	err := collection.FindOne(c.Request.Context(), bson.M{"_id": clave}).Decode(&registro)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// La primera solicitud fallo y libero la clave entre ambas consultas
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "La solicitud anterior con esta clave falló, reintente"})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if registro.Hash != hash {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "La clave de idempotencia ya se usó con otra solicitud"})
+		return
+	}
+	if registro.Estado != idempotenciaCompletada {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "Hay una solicitud en curso con esta clave de idempotencia"})
+		return
+	}
+
+	c.Header("Idempotent-Replayed", "true")
+	c.Data(registro.EstadoHTTP, registro.TipoCuerpo, registro.Cuerpo)
+	c.Abort()
+}
+
+// Guarda una copia de lo que se escribe en la respuesta
+type escritorCapturado struct {
+	gin.ResponseWriter
+	cuerpo bytes.Buffer
+}
+
+func (e *escritorCapturado) Write(datos []byte) (int, error) {
+	e.cuerpo.Write(datos)
+	return e.ResponseWriter.Write(datos)
+}
+
+func (e *escritorCapturado) WriteString(datos string) (int, error) {
+	e.cuerpo.WriteString(datos)
+	return e.ResponseWriter.WriteString(datos)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Router con la ruta POST /prueba detras de idempotente(). El handler
+// cuenta sus ejecuciones y responde lo que indique responder
+func routerIdempotente(t *testing.T, responder func(c *gin.Context)) (*gin.Engine, *int32) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	anterior := config
+	t.Cleanup(func() { config = anterior })
+	config = configuracionPorDefecto()
+	nuevoMongoFalso(t)
+
+	var ejecuciones int32
+	r := gin.New()
+	r.POST("/prueba", idempotente(), func(c *gin.Context) {
+		atomic.AddInt32(&ejecuciones, 1)
+		responder(c)
+	})
+	return r, &ejecuciones
+}
+
+// Envia cuerpo a la ruta con la clave de idempotencia indicada
+func enviarConClave(r http.Handler, ruta, clave, cuerpo string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, ruta, strings.NewReader(cuerpo))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(cabeceraIdempotencia, clave)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotenteRepiteLaRespuestaGuardada(t *testing.T) {
+	r, ejecuciones := routerIdempotente(t, func(c *gin.Context) {
+		c.JSON(http.StatusCreated, gin.H{"id": "ABC"})
+	})
+
+	primera := enviarConClave(r, "/prueba", "clave-1", `{"a":1}`)
+	segunda := enviarConClave(r, "/prueba", "clave-1", `{"a":1}`)
+	if *ejecuciones != 1 {
+		t.Errorf("el handler se ejecutó %d veces, se esperaba una", *ejecuciones)
+	}
+	if segunda.Code != http.StatusCreated || segunda.Body.String() != primera.Body.String() {
+		t.Errorf("repetida = %d %s, se esperaba %d %s", segunda.Code, segunda.Body, primera.Code, primera.Body)
+	}
+	if segunda.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("la respuesta repetida no trae Idempotent-Replayed")
+	}
+}
+
+func TestIdempotenteRechazaOtroCuerpo(t *testing.T) {
+	r, ejecuciones := routerIdempotente(t, func(c *gin.Context) {
+		c.JSON(http.StatusCreated, gin.H{"id": "ABC"})
+	})
+
+	enviarConClave(r, "/prueba", "clave-1", `{"a":1}`)
+	if w := enviarConClave(r, "/prueba", "clave-1", `{"a":2}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("otro cuerpo: estado = %d, se esperaba 422", w.Code)
+	}
+	if *ejecuciones != 1 {
+		t.Errorf("el handler se ejecutó %d veces, se esperaba una", *ejecuciones)
+	}
+}
+
+func TestIdempotenteRespondeConflictoEnProceso(t *testing.T) {
+	entro, seguir := make(chan struct{}), make(chan struct{})
+	r, _ := routerIdempotente(t, func(c *gin.Context) {
+		close(entro)
+		<-seguir
+		c.JSON(http.StatusCreated, gin.H{"id": "ABC"})
+	})
+
+	terminada := make(chan *httptest.ResponseRecorder)
+	go func() { terminada <- enviarConClave(r, "/prueba", "clave-1", `{"a":1}`) }()
+	<-entro
+	w := enviarConClave(r, "/prueba", "clave-1", `{"a":1}`)
+	close(seguir)
+	if w.Code != http.StatusConflict {
+		t.Errorf("en proceso: estado = %d, se esperaba 409", w.Code)
+	}
+	if primera := <-terminada; primera.Code != http.StatusCreated {
+		t.Errorf("primera solicitud: estado = %d", primera.Code)
+	}
+}
+
+func TestIdempotenteLiberaOConservaLaClave(t *testing.T) {
+	conservar := false
+	r, ejecuciones := routerIdempotente(t, func(c *gin.Context) {
+		if conservar {
+			conservarClaveIdempotencia(c)
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": "falló"})
+	})
+
+	// Un error 5xx libera la clave y el reintento se vuelve a procesar
+	enviarConClave(r, "/prueba", "clave-1", `{"a":1}`)
+	enviarConClave(r, "/prueba", "clave-1", `{"a":1}`)
+	if *ejecuciones != 2 {
+		t.Errorf("clave liberada: el handler se ejecutó %d veces, se esperaban dos", *ejecuciones)
+	}
+
+	// Con la clave conservada el reintento recibe 409 sin ejecutar la ruta
+	conservar = true
+	enviarConClave(r, "/prueba", "clave-2", `{"a":1}`)
+	if w := enviarConClave(r, "/prueba", "clave-2", `{"a":1}`); w.Code != http.StatusConflict {
+		t.Errorf("clave conservada: estado = %d, se esperaba 409", w.Code)
+	}
+	if *ejecuciones != 3 {
+		t.Errorf("clave conservada: el handler se ejecutó %d veces, se esperaban tres", *ejecuciones)
+	}
+}
+
+func TestHacerReservaConErrorDelProveedorConservaLaClave(t *testing.T) {
+	r := prepararReproduccion(t)
+	mongo := nuevoMongoFalso(t)
+
+	// El proveedor responde 504 a la reserva: la orden pudo haberse creado
+	var reservas int32
+	proveedor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if req.URL.Path == rutaToken {
+			w.Write([]byte(tokenFixture))
+			return
+		}
+		atomic.AddInt32(&reservas, 1)
+		w.WriteHeader(http.StatusGatewayTimeout)
+		w.Write([]byte(`{"errors":[{"status":504,"title":"GATEWAY TIMEOUT"}]}`))
+	}))
+	defer proveedor.Close()
+	config.URLProveedor = proveedor.URL
+	clienteAmadeus = nuevoClienteAmadeus(http.DefaultTransport)
+
+	cuerpo, err := os.ReadFile(filepath.Join("testdata", "solicitudes", "booking.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := enviarConClave(r, "/booking", "reserva-1", string(cuerpo)); w.Code != http.StatusBadGateway {
+		t.Fatalf("estado = %d, se esperaba 502: %s", w.Code, w.Body)
+	}
+	if w := enviarConClave(r, "/booking", "reserva-1", string(cuerpo)); w.Code != http.StatusConflict {
+		t.Errorf("reintento: estado = %d, se esperaba 409: %s", w.Code, w.Body)
+	}
+	if reservas != 1 {
+		t.Errorf("el proveedor recibió %d reservas, se esperaba una", reservas)
+	}
+	if guardadas := mongo.documentos(coleccionReservas); len(guardadas) != 0 {
+		t.Errorf("se guardaron %d reservas", len(guardadas))
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+var (
+	solicitudesHTTP = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gotravel_http_requests_total",
+		Help: "Solicitudes HTTP recibidas por ruta, metodo y estado.",
+	}, []string{"ruta", "metodo", "estado"})
+
+	latenciaHTTP = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gotravel_http_request_duration_seconds",
+		Help:    "Latencia de las solicitudes HTTP por ruta y metodo.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"ruta", "metodo"})
+
+	latenciaProveedor = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gotravel_upstream_request_duration_seconds",
+		Help:    "Latencia de las llamadas a Amadeus por endpoint.",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"endpoint", "metodo"})
+
+	erroresProveedor = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gotravel_upstream_errors_total",
+		Help: "Llamadas a Amadeus fallidas (error de red o estado >= 400) por endpoint.",
+	}, []string{"endpoint", "metodo", "estado"})
+
+	renovacionesToken = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gotravel_token_refresh_total",
+		Help: "Tokens de acceso solicitados a Amadeus por resultado.",
+	}, []string{"resultado"})
+
+	latenciaMongo = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gotravel_mongo_operation_duration_seconds",
+		Help:    "Duracion de las operaciones de MongoDB por comando y resultado.",
+		Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
+	}, []string{"operacion", "resultado"})
+
+	reservasRealizadas = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gotravel_bookings_total",
+		Help: "Reservas intentadas por resultado.",
+	}, []string{"resultado"})
+
+	reservasParaRevision = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gotravel_bookings_needing_review_total",
+		Help: "Reservas creadas en Amadeus que no se pudieron guardar completas, por motivo.",
+	}, []string{"motivo"})
+)
+
+// Middleware que mide cada solicitud HTTP. Se usa la ruta registrada (por
+// ejemplo /booking/:id/calendar.ics) para no crear una serie por id
+func medirSolicitudes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		inicio := time.Now()
+		c.Next()
+
+		ruta := c.FullPath()
+		if ruta == "" {
+			ruta = "desconocida"
+		}
+		solicitudesHTTP.WithLabelValues(ruta, c.Request.Method, strconv.Itoa(c.Writer.Status())).Inc()
+		latenciaHTTP.WithLabelValues(ruta, c.Request.Method).Observe(time.Since(inicio).Seconds())
+	}
+}
+
+func exponerMetricas() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+// Transporte HTTP que mide la latencia y los errores de cada llamada al
+// proveedor
+type transporteMedido struct {
+	base http.RoundTripper
+}
+
+func (t transporteMedido) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := endpointProveedor(req.URL.Path)
+
+	inicio := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	latenciaProveedor.WithLabelValues(endpoint, req.Method).Observe(time.Since(inicio).Seconds())
+
+	if err != nil {
+		erroresProveedor.WithLabelValues(endpoint, req.Method, "error").Inc()
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		erroresProveedor.WithLabelValues(endpoint, req.Method, strconv.Itoa(resp.StatusCode)).Inc()
+	}
+	return resp, nil
+}
+
+var idEnRuta = regexp.MustCompile(`/flight-orders/[^/]+`)
+
+// Normaliza la ruta del proveedor reemplazando los ids de reserva
+func endpointProveedor(ruta string) string {
+	return idEnRuta.ReplaceAllString(ruta, "/flight-orders/{id}")
+}
+
+// Monitor de comandos de MongoDB que registra la duracion de cada operacion
+var monitorMongo = &event.CommandMonitor{
+	Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+		latenciaMongo.WithLabelValues(e.CommandName, "ok").Observe(e.Duration.Seconds())
+	},
+	Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+		latenciaMongo.WithLabelValues(e.CommandName, "error").Observe(e.Duration.Seconds())
+	},
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSolicitudConPanicSeMide(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := nuevoRouter()
+	r.GET("/prueba/panico", func(c *gin.Context) { panic("falla de prueba") })
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/prueba/panico", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("estado = %d, se esperaba 500", rec.Code)
+	}
+	if n := testutil.ToFloat64(solicitudesHTTP.WithLabelValues("/prueba/panico", http.MethodGet, "500")); n != 1 {
+		t.Errorf("solicitudes medidas = %v, se esperaba 1", n)
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Codigos de operacion del protocolo de MongoDB que usa el driver
+const (
+	opReply = 1
+	opQuery = 2004
+	opMsg   = 2013
+)
+
+// Servidor MongoDB minimo para los tests de handlers. Responde el saludo y
+// el ping del driver, guarda los documentos insertados y los filtros
+// consultados por coleccion. Las consultas, actualizaciones ($set) y
+// borrados por _id se aplican sobre los documentos guardados; las demas
+// consultas reciben un cursor vacio
+type mongoFalso struct {
+	mu         sync.Mutex
+	insertados map[string][]bson.Raw
+	filtros    map[string][]bson.Raw
+}
+
+// Levanta el servidor en un puerto libre y apunta config.MongoURI a el
+func nuevoMongoFalso(t *testing.T) *mongoFalso {
+	t.Helper()
+	escucha, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { escucha.Close() })
+
+	m := &mongoFalso{insertados: make(map[string][]bson.Raw), filtros: make(map[string][]bson.Raw)}
+	go func() {
+		for {
+			conexion, err := escucha.Accept()
+			if err != nil {
+				return
+			}
+			go m.atender(conexion)
+		}
+	}()
+
+	anterior := config.MongoURI
+	config.MongoURI = "mongodb://" + escucha.Addr().String() + "/?directConnection=true"
+	t.Cleanup(func() { config.MongoURI = anterior })
+	return m
+}
+
+// Documentos insertados en la coleccion
+func (m *mongoFalso) documentos(coleccion string) []bson.Raw {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]bson.Raw(nil), m.insertados[coleccion]...)
+}
+
+// Filtros de las consultas find sobre la coleccion
+func (m *mongoFalso) consultas(coleccion string) []bson.Raw {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]bson.Raw(nil), m.filtros[coleccion]...)
+}
+
+func (m *mongoFalso) atender(conexion net.Conn) {
+	defer conexion.Close()
+	for {
+		var encabezado [16]byte
+		if _, err := io.ReadFull(conexion, encabezado[:]); err != nil {
+			return
+		}
+		largo := int32(binary.LittleEndian.Uint32(encabezado[0:]))
+		idSolicitud := int32(binary.LittleEndian.Uint32(encabezado[4:]))
+		operacion := int32(binary.LittleEndian.Uint32(encabezado[12:]))
+		cuerpo := make([]byte, largo-16)
+		if _, err := io.ReadFull(conexion, cuerpo); err != nil {
+			return
+		}
+
+		var respuesta []byte
+		switch operacion {
+		case opQuery:
+			// flags, coleccion, numberToSkip, numberToReturn y el comando
+			fin := 4 + bytes.IndexByte(cuerpo[4:], 0) + 1
+			comando := bson.Raw(cuerpo[fin+8:])
+			var datos bytes.Buffer
+			binary.Write(&datos, binary.LittleEndian, int32(0))
+			binary.Write(&datos, binary.LittleEndian, int64(0))
+			binary.Write(&datos, binary.LittleEndian, int32(0))
+			binary.Write(&datos, binary.LittleEndian, int32(1))
+			datos.Write(m.responder(comando, nil))
+			respuesta = mensajeMongo(idSolicitud, opReply, datos.Bytes())
+		case opMsg:
+			comando, documentos := leerSecciones(cuerpo[4:])
+			var datos bytes.Buffer
+			binary.Write(&datos, binary.LittleEndian, uint32(0))
+			datos.WriteByte(0)
+			datos.Write(m.responder(comando, documentos))
+			respuesta = mensajeMongo(idSolicitud, opMsg, datos.Bytes())
+		default:
+			return
+		}
+		if _, err := conexion.Write(respuesta); err != nil {
+			return
+		}
+	}
+}
+
+// Lee las secciones de un OP_MSG: el comando y, si los hay, los
+// documentos enviados como secuencia
+func leerSecciones(secciones []byte) (bson.Raw, []bson.Raw) {
+	var comando bson.Raw
+	var documentos []bson.Raw
+	for len(secciones) > 0 {
+		tipo := secciones[0]
+		secciones = secciones[1:]
+		largo := int(binary.LittleEndian.Uint32(secciones))
+		if tipo == 0 {
+			comando = bson.Raw(secciones[:largo])
+		} else {
+			secuencia := secciones[4:largo]
+			secuencia = secuencia[bytes.IndexByte(secuencia, 0)+1:]
+			for len(secuencia) > 0 {
+				n := int(binary.LittleEndian.Uint32(secuencia))
+				documentos = append(documentos, bson.Raw(secuencia[:n]))
+				secuencia = secuencia[n:]
+			}
+		}
+		secciones = secciones[largo:]
+	}
+	return comando, documentos
+}
+
+func (m *mongoFalso) responder(comando bson.Raw, documentos []bson.Raw) []byte {
+	elementos, _ := comando.Elements()
+	nombre, coleccion := "", ""
+	if len(elementos) > 0 {
+		nombre = elementos[0].Key()
+		coleccion, _ = elementos[0].Value().StringValueOK()
+	}
+
+	var respuesta bson.M
+	switch strings.ToLower(nombre) {
+	case "hello", "ismaster":
+		respuesta = bson.M{
+			"helloOk":             true,
+			"ismaster":            true,
+			"maxBsonObjectSize":   16 * 1024 * 1024,
+			"maxMessageSizeBytes": 48000000,
+			"maxWriteBatchSize":   100000,
+			"localTime":           time.Now(),
+			"minWireVersion":      0,
+			"maxWireVersion":      17,
+			"ok":                  1,
+		}
+	case "insert":
+		documentos = append(documentos, documentosDe(comando, "documents")...)
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		insertados := 0
+		var errores bson.A
+		for i, documento := range documentos {
+			// Como en MongoDB, el _id no se puede repetir
+			if m.posicion(coleccion, documento.Lookup("_id")) >= 0 {
+				errores = append(errores, bson.M{"index": i, "code": 11000, "errmsg": "E11000 duplicate key error"})
+				continue
+			}
+			m.insertados[coleccion] = append(m.insertados[coleccion], documento)
+			insertados++
+		}
+		respuesta = bson.M{"n": insertados, "ok": 1}
+		if len(errores) > 0 {
+			respuesta["writeErrors"] = errores
+		}
+	case "find", "aggregate":
+		encontrados := bson.A{}
+		if filtro, err := comando.LookupErr("filter"); err == nil {
+			m.mu.Lock()
+			m.filtros[coleccion] = append(m.filtros[coleccion], filtro.Document())
+			if i := m.posicion(coleccion, filtro.Document().Lookup("_id")); i >= 0 {
+				encontrados = append(encontrados, m.insertados[coleccion][i])
+			}
+			m.mu.Unlock()
+		}
+		respuesta = bson.M{"cursor": bson.M{"id": int64(0), "ns": baseDeDatos + "." + coleccion, "firstBatch": encontrados}, "ok": 1}
+	case "update":
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		modificados := 0
+		for _, cambio := range append(documentos, documentosDe(comando, "updates")...) {
+			i := m.posicion(coleccion, cambio.Lookup("q", "_id"))
+			valores, err := cambio.LookupErr("u", "$set")
+			if i < 0 || err != nil {
+				continue
+			}
+			var documento bson.M
+			bson.Unmarshal(m.insertados[coleccion][i], &documento)
+			var set bson.M
+			bson.Unmarshal(valores.Document(), &set)
+			for campo, valor := range set {
+				documento[campo] = valor
+			}
+			m.insertados[coleccion][i], _ = bson.Marshal(documento)
+			modificados++
+		}
+		respuesta = bson.M{"n": modificados, "nModified": modificados, "ok": 1}
+	case "delete":
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		borrados := 0
+		for _, borrado := range append(documentos, documentosDe(comando, "deletes")...) {
+			if i := m.posicion(coleccion, borrado.Lookup("q", "_id")); i >= 0 {
+				m.insertados[coleccion] = append(m.insertados[coleccion][:i], m.insertados[coleccion][i+1:]...)
+				borrados++
+			}
+		}
+		respuesta = bson.M{"n": borrados, "ok": 1}
+	default:
+		respuesta = bson.M{"ok": 1}
+	}
+	contenido, _ := bson.Marshal(respuesta)
+	return contenido
+}
+
+// Documentos del arreglo campo del comando
+func documentosDe(comando bson.Raw, campo string) []bson.Raw {
+	var documentos []bson.Raw
+	if valor, err := comando.LookupErr(campo); err == nil {
+		valores, _ := valor.Array().Values()
+		for _, v := range valores {
+			documentos = append(documentos, v.Document())
+		}
+	}
+	return documentos
+}
+
+// Posicion del documento con ese _id en la coleccion, o -1. Se llama con
+// m.mu tomado
+func (m *mongoFalso) posicion(coleccion string, id bson.RawValue) int {
+	if id.Type == 0 {
+		return -1
+	}
+	for i, documento := range m.insertados[coleccion] {
+		if actual := documento.Lookup("_id"); actual.Type == id.Type && bytes.Equal(actual.Value, id.Value) {
+			return i
+		}
+	}
+	return -1
+}
+
+func mensajeMongo(respondeA int32, operacion int32, datos []byte) []byte {
+	var mensaje bytes.Buffer
+	binary.Write(&mensaje, binary.LittleEndian, int32(16+len(datos)))
+	binary.Write(&mensaje, binary.LittleEndian, int32(0))
+	binary.Write(&mensaje, binary.LittleEndian, respondeA)
+	binary.Write(&mensaje, binary.LittleEndian, operacion)
+	mensaje.Write(datos)
+	return mensaje.Bytes()
+}
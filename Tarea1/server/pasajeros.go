@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Edades permitidas por tipo de pasajero, en años cumplidos al dia del
+// primer vuelo. Un maximo de -1 indica que no hay limite
+var edadesPorTipo = map[string]struct{ minima, maxima int }{
+	"ADULT":         {12, -1},
+	"SENIOR":        {60, -1},
+	"CHILD":         {2, 11},
+	"HELD_INFANT":   {0, 1},
+	"SEATED_INFANT": {0, 1},
+}
+
+// Valida que los pasajeros de una reserva correspondan a los tipos de la
+// oferta: cada pasajero debe tener su precio, su edad debe calzar con el
+// tipo y cada infante en brazos debe ir asociado a un adulto o mayor
+// distinto
+func validarPasajeros(reserva Booking) []string {
+	if len(reserva.Data.FlightOffers) == 0 {
+		return []string{"la reserva no incluye ofertas"}
+	}
+	oferta := reserva.Data.FlightOffers[0]
+
+	var fechaVuelo time.Time
+	if len(oferta.Itineraries) > 0 && len(oferta.Itineraries[0].Segments) > 0 {
+		fechaVuelo, _ = time.Parse(formatoAmadeus, oferta.Itineraries[0].Segments[0].Departure.At)
+	}
+	if fechaVuelo.IsZero() {
+		fechaVuelo = time.Now()
+	}
+
+	precios := make(map[string]TravelerPricing)
+	for _, pricing := range oferta.TravelerPricings {
+		precios[pricing.TravelerId] = pricing
+	}
+
+	var errores []string
+	asociados := make(map[string]string)
+	for _, viajero := range reserva.Data.Travelers {
+		pricing, ok := precios[viajero.Id]
+		if !ok {
+			errores = append(errores, fmt.Sprintf("pasajero %s: no tiene precio en la oferta", viajero.Id))
+			continue
+		}
+		delete(precios, viajero.Id)
+
+		if rango, ok := edadesPorTipo[pricing.TravelerType]; ok {
+			nacimiento, err := time.Parse(formatoFecha, viajero.DateOfBirth)
+			if err != nil {
+				errores = append(errores, fmt.Sprintf("pasajero %s: fecha de nacimiento no válida (AAAA-MM-DD)", viajero.Id))
+			} else if edad := edadAl(nacimiento, fechaVuelo); edad < rango.minima || (rango.maxima >= 0 && edad > rango.maxima) {
+				errores = append(errores, fmt.Sprintf("pasajero %s: tiene %d años y no corresponde al tipo %s", viajero.Id, edad, pricing.TravelerType))
+			}
+		}
+
+		if pricing.TravelerType != "HELD_INFANT" {
+			continue
+		}
+		adulto, ok := tipoPasajero(oferta, pricing.AssociatedAdultId)
+		if !ok || (adulto != "ADULT" && adulto != "SENIOR") {
+			errores = append(errores, fmt.Sprintf("pasajero %s: el infante debe ir asociado a un adulto o mayor", viajero.Id))
+			continue
+		}
+		if otro, repetido := asociados[pricing.AssociatedAdultId]; repetido {
+			errores = append(errores, fmt.Sprintf("pasajero %s: el adulto %s ya lleva al infante %s", viajero.Id, pricing.AssociatedAdultId, otro))
+			continue
+		}
+		asociados[pricing.AssociatedAdultId] = viajero.Id
+	}
+
+	// Se ordenan para que el mensaje no cambie entre solicitudes
+	faltantes := make([]string, 0, len(precios))
+	for id := range precios {
+		faltantes = append(faltantes, id)
+	}
+	sort.Strings(faltantes)
+	for _, id := range faltantes {
+		errores = append(errores, fmt.Sprintf("pasajero %s: falta en la reserva", id))
+	}
+	return errores
+}
+
+func tipoPasajero(oferta FlightOffer, id string) (string, bool) {
+	for _, pricing := range oferta.TravelerPricings {
+		if pricing.TravelerId == id {
+			return pricing.TravelerType, true
+		}
+	}
+	return "", false
+}
+
+// Años cumplidos a la fecha indicada
+func edadAl(nacimiento, fecha time.Time) int {
+	edad := fecha.Year() - nacimiento.Year()
+	if fecha.Month() < nacimiento.Month() || (fecha.Month() == nacimiento.Month() && fecha.Day() < nacimiento.Day()) {
+		edad--
+	}
+	return edad
+}
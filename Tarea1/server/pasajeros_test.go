@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// Reserva de un vuelo el 2030-03-10 con los precios (id, tipo y adulto
+// asociado) y los pasajeros (id y fecha de nacimiento) indicados
+func reservaPasajeros(t *testing.T, precios [][3]string, pasajeros [][2]string) Booking {
+	t.Helper()
+	oferta := ofertaPrueba("1", "IB6830", "812.34")
+	for _, precio := range precios {
+		oferta.TravelerPricings = append(oferta.TravelerPricings, TravelerPricing{TravelerId: precio[0], TravelerType: precio[1], AssociatedAdultId: precio[2]})
+	}
+	var viajeros []map[string]string
+	for _, pasajero := range pasajeros {
+		viajeros = append(viajeros, map[string]string{"id": pasajero[0], "dateOfBirth": pasajero[1]})
+	}
+	contenido, err := json.Marshal(map[string]interface{}{"data": map[string]interface{}{
+		"flightOffers": []FlightOffer{oferta},
+		"travelers":    viajeros,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reserva Booking
+	if err := json.Unmarshal(contenido, &reserva); err != nil {
+		t.Fatal(err)
+	}
+	return reserva
+}
+
+func TestValidarPasajeros(t *testing.T) {
+	casos := []struct {
+		nombre    string
+		precios   [][3]string
+		pasajeros [][2]string
+		errores   []string
+	}{
+		{
+			"edades validas",
+			[][3]string{{"1", "ADULT", ""}, {"2", "CHILD", ""}, {"3", "HELD_INFANT", "1"}, {"4", "SENIOR", ""}},
+			[][2]string{{"1", "1990-05-20"}, {"2", "2019-03-10"}, {"3", "2029-03-11"}, {"4", "1970-03-10"}},
+			nil,
+		},
+		{
+			"edades fuera del tipo",
+			[][3]string{{"1", "ADULT", ""}, {"2", "CHILD", ""}, {"3", "CHILD", ""}, {"4", "SENIOR", ""}},
+			[][2]string{{"1", "2018-03-11"}, {"2", "2018-03-11"}, {"3", "2028-03-11"}, {"4", "1970-03-11"}},
+			[]string{
+				"pasajero 1: tiene 11 años y no corresponde al tipo ADULT",
+				"pasajero 3: tiene 1 años y no corresponde al tipo CHILD",
+				"pasajero 4: tiene 59 años y no corresponde al tipo SENIOR",
+			},
+		},
+		{
+			"infante con dos años cumplidos",
+			[][3]string{{"1", "ADULT", ""}, {"2", "HELD_INFANT", "1"}},
+			[][2]string{{"1", "1990-05-20"}, {"2", "2028-03-10"}},
+			[]string{"pasajero 2: tiene 2 años y no corresponde al tipo HELD_INFANT"},
+		},
+		{
+			"infante sin adulto",
+			[][3]string{{"1", "CHILD", ""}, {"2", "HELD_INFANT", "1"}, {"3", "HELD_INFANT", "9"}},
+			[][2]string{{"1", "2020-01-01"}, {"2", "2029-06-01"}, {"3", "2029-06-01"}},
+			[]string{
+				"pasajero 2: el infante debe ir asociado a un adulto o mayor",
+				"pasajero 3: el infante debe ir asociado a un adulto o mayor",
+			},
+		},
+		{
+			"adulto con dos infantes",
+			[][3]string{{"1", "ADULT", ""}, {"2", "HELD_INFANT", "1"}, {"3", "HELD_INFANT", "1"}},
+			[][2]string{{"1", "1990-05-20"}, {"2", "2029-06-01"}, {"3", "2029-06-01"}},
+			[]string{"pasajero 3: el adulto 1 ya lleva al infante 2"},
+		},
+		{
+			"pasajeros sin precio y faltantes",
+			[][3]string{{"1", "ADULT", ""}, {"2", "ADULT", ""}, {"3", "ADULT", ""}, {"4", "ADULT", ""}},
+			[][2]string{{"1", "1990-05-20"}, {"9", "1990-05-20"}},
+			[]string{
+				"pasajero 9: no tiene precio en la oferta",
+				"pasajero 2: falta en la reserva",
+				"pasajero 3: falta en la reserva",
+				"pasajero 4: falta en la reserva",
+			},
+		},
+		{
+			"fecha no valida",
+			[][3]string{{"1", "ADULT", ""}},
+			[][2]string{{"1", "20/05/1990"}},
+			[]string{"pasajero 1: fecha de nacimiento no válida (AAAA-MM-DD)"},
+		},
+	}
+	for _, caso := range casos {
+		errores := validarPasajeros(reservaPasajeros(t, caso.precios, caso.pasajeros))
+		if !reflect.DeepEqual(errores, caso.errores) {
+			t.Errorf("%s: errores = %q, se esperaba %q", caso.nombre, errores, caso.errores)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Politica de viajes de la empresa. Se carga desde un archivo JSON, por
+// ejemplo:
+//
+//	{
+//	  "cabinRules": [{"underDuration": "6h", "allowedCabins": ["ECONOMY"]}],
+//	  "maxPrices": [{"route": "SCL-LIM", "amount": 300000, "currency": "CLP"}],
+//	  "preferredCarriers": ["LA", "H2"]
+//	}
+type PoliticaViajes struct {
+	Cabinas       []ReglaCabina  `json:"cabinRules"`
+	PreciosMaximo []PrecioMaximo `json:"maxPrices"`
+	Aerolineas    []string       `json:"preferredCarriers"`
+}
+
+// Cabinas permitidas en los itinerarios que duran menos de MenosDe
+type ReglaCabina struct {
+	MenosDe    string   `json:"underDuration"`
+	Permitidas []string `json:"allowedCabins"`
+
+	duracion time.Duration
+}
+
+// Precio maximo de una ruta (ORIGEN-DESTINO del viaje de ida, o * para
+// todas). Solo se compara con ofertas en la misma moneda
+type PrecioMaximo struct {
+	Ruta   string  `json:"route"`
+	Monto  float64 `json:"amount"`
+	Moneda string  `json:"currency"`
+}
+
+// Resultado de evaluar una oferta contra la politica
+type CumplimientoPolitica struct {
+	Cumple       bool     `json:"compliant" bson:"cumple"`
+	Infracciones []string `json:"violations,omitempty" bson:"infracciones,omitempty"`
+}
+
+// Infracciones y motivo indicados al reservar una oferta fuera de la
+// politica
+type AprobacionPolitica struct {
+	Infracciones []string `bson:"infracciones" json:"violations"`
+	Motivo       string   `bson:"motivo" json:"reason"`
+	Solicitud    string   `bson:"solicitud,omitempty" json:"approvalId,omitempty"`
+}
+
+// Documento guardado en MongoDB para una reserva. Crudo guarda la respuesta
+// del proveedor cuando no se pudo leer como reserva, para revisarla a mano
+type ReservaGuardada struct {
+	Booking          `bson:",inline"`
+	Aprobacion       *AprobacionPolitica `bson:"politica,omitempty"`
+	Crudo            string              `bson:"crudo,omitempty"`
+	RequiereRevision bool                `bson:"requiereRevision,omitempty"`
+	Historial        []CambioReserva     `bson:"historial,omitempty"`
+}
+
+// Lee y valida el archivo de politica. Un archivo vacio desactiva la
+// politica
+func cargarPolitica(archivo string) (*PoliticaViajes, error) {
+	if archivo == "" {
+		return nil, nil
+	}
+
+	contenido, err := os.ReadFile(archivo)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo leer la política de viajes: %w", err)
+	}
+	var p PoliticaViajes
+	if err := json.Unmarshal(contenido, &p); err != nil {
+		return nil, fmt.Errorf("política de viajes no válida: %w", err)
+	}
+
+	for i := range p.Cabinas {
+		duracion, err := time.ParseDuration(p.Cabinas[i].MenosDe)
+		if err != nil || duracion <= 0 {
+			return nil, fmt.Errorf("política de viajes: underDuration no válido: %q", p.Cabinas[i].MenosDe)
+		}
+		if len(p.Cabinas[i].Permitidas) == 0 {
+			return nil, fmt.Errorf("política de viajes: la regla de %s no indica cabinas", p.Cabinas[i].MenosDe)
+		}
+		p.Cabinas[i].duracion = duracion
+	}
+	for _, precio := range p.PreciosMaximo {
+		if precio.Ruta == "" || precio.Monto <= 0 || precio.Moneda == "" {
+			return nil, fmt.Errorf("política de viajes: precio máximo incompleto para la ruta %q", precio.Ruta)
+		}
+	}
+	return &p, nil
+}
+
+// Revisa una oferta contra cada regla de la politica
+func (p *PoliticaViajes) evaluar(oferta FlightOffer) CumplimientoPolitica {
+	if p == nil {
+		return CumplimientoPolitica{Cumple: true}
+	}
+	var infracciones []string
+
+	// Cabina de cada segmento, de todos los pasajeros
+	cabinas := make(map[string][]string)
+	for _, pricing := range oferta.TravelerPricings {
+		for _, detalle := range pricing.FareDetailsBySegment {
+			cabinas[detalle.SegmentId] = append(cabinas[detalle.SegmentId], detalle.Cabin)
+		}
+	}
+	for _, regla := range p.Cabinas {
+		for _, itinerario := range oferta.Itineraries {
+			// Si no se conoce la duracion la regla se aplica igual
+			if duracion, ok := duracionISO(itinerario.Duration); ok && duracion >= regla.duracion {
+				continue
+			}
+			for _, segmento := range itinerario.Segments {
+				for _, cabina := range cabinas[segmento.Id] {
+					if !contiene(regla.Permitidas, cabina) {
+						infracciones = append(infracciones, fmt.Sprintf("Cabina %s en el segmento %s %s-%s (viajes de menos de %s: %s)",
+							cabina, segmento.CarrierCode+segmento.Number, segmento.Departure.IataCode, segmento.Arrival.IataCode,
+							regla.MenosDe, strings.Join(regla.Permitidas, ", ")))
+						break
+					}
+				}
+			}
+		}
+	}
+
+	ruta := rutaOferta(oferta)
+	for _, maximo := range p.PreciosMaximo {
+		if maximo.Ruta != "*" && !strings.EqualFold(maximo.Ruta, ruta) {
+			continue
+		}
+		if !strings.EqualFold(maximo.Moneda, oferta.Price.Currency) {
+			continue
+		}
+		if precio := precioOferta(oferta); precio > maximo.Monto {
+			infracciones = append(infracciones, fmt.Sprintf("Precio %s %s sobre el máximo de %s %s para %s",
+				oferta.Price.GrandTotal, oferta.Price.Currency, strconv.FormatFloat(maximo.Monto, 'f', -1, 64), maximo.Moneda, maximo.Ruta))
+		}
+	}
+
+	if len(p.Aerolineas) > 0 {
+		for _, itinerario := range oferta.Itineraries {
+			for _, segmento := range itinerario.Segments {
+				if !contiene(p.Aerolineas, segmento.CarrierCode) {
+					infracciones = append(infracciones, fmt.Sprintf("Aerolínea %s no preferida (segmento %s)",
+						segmento.CarrierCode, segmento.CarrierCode+segmento.Number))
+				}
+			}
+		}
+	}
+
+	return CumplimientoPolitica{Cumple: len(infracciones) == 0, Infracciones: infracciones}
+}
+
+// Politica de la agencia de la solicitud. Sin politica todas las ofertas
+// la cumplen
+func politicaDe(ctx context.Context) *PoliticaViajes {
+	if agencia := agenciaDe(ctx); agencia != nil {
+		return agencia.politica
+	}
+	return nil
+}
+
+// Marca cada oferta con el resultado de la politica
+func agregarPolitica(ctx context.Context, ofertas []FlightOffer) {
+	politica := politicaDe(ctx)
+	if politica == nil {
+		return
+	}
+	for i := range ofertas {
+		cumplimiento := politica.evaluar(ofertas[i])
+		ofertas[i].Politica = &cumplimiento
+	}
+}
+
+// Infracciones de las ofertas de una reserva. Se evaluan de nuevo en vez de
+// confiar en la marca que envia el cliente
+func infraccionesPolitica(ctx context.Context, ofertas []FlightOffer) []string {
+	politica := politicaDe(ctx)
+	var infracciones []string
+	for _, oferta := range ofertas {
+		infracciones = append(infracciones, politica.evaluar(oferta).Infracciones...)
+	}
+	return infracciones
+}
+
+// Origen y destino del viaje de ida, por ejemplo SCL-LIM
+func rutaOferta(oferta FlightOffer) string {
+	if len(oferta.Itineraries) == 0 || len(oferta.Itineraries[0].Segments) == 0 {
+		return ""
+	}
+	segmentos := oferta.Itineraries[0].Segments
+	return segmentos[0].Departure.IataCode + "-" + segmentos[len(segmentos)-1].Arrival.IataCode
+}
+
+var patronDuracionISO = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?)?$`)
+
+// Convierte una duracion ISO 8601 de Amadeus (por ejemplo PT5H30M)
+func duracionISO(valor string) (time.Duration, bool) {
+	partes := patronDuracionISO.FindStringSubmatch(valor)
+	if partes == nil || valor == "P" || valor == "PT" {
+		return 0, false
+	}
+	var duracion time.Duration
+	for i, unidad := range []time.Duration{24 * time.Hour, time.Hour, time.Minute} {
+		if partes[i+1] == "" {
+			continue
+		}
+		n, _ := strconv.Atoi(partes[i+1])
+		duracion += time.Duration(n) * unidad
+	}
+	return duracion, true
+}
+
+func contiene(lista []string, valor string) bool {
+	for _, elemento := range lista {
+		if strings.EqualFold(elemento, valor) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Oferta SCL-MAD de un segmento con la duracion, la moneda y la cabina
+// indicadas
+func ofertaPolitica(vuelo, total, moneda, duracion, cabina string) FlightOffer {
+	oferta := ofertaPrueba("1", vuelo, total)
+	oferta.Price.Currency = moneda
+	oferta.Itineraries[0].Duration = duracion
+	oferta.Itineraries[0].Segments[0].Id = "1"
+	oferta.TravelerPricings = []TravelerPricing{{
+		TravelerId:           "1",
+		FareDetailsBySegment: []FareDetailsBySegment{{SegmentId: "1", Cabin: cabina}},
+	}}
+	return oferta
+}
+
+// Escribe la politica en un archivo temporal y la carga
+func politicaPrueba(t *testing.T, contenido string) (*PoliticaViajes, error) {
+	t.Helper()
+	archivo := filepath.Join(t.TempDir(), "politica.json")
+	if err := os.WriteFile(archivo, []byte(contenido), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return cargarPolitica(archivo)
+}
+
+func TestEvaluarPolitica(t *testing.T) {
+	politica, err := politicaPrueba(t, `{
+		"cabinRules": [{"underDuration": "6h", "allowedCabins": ["ECONOMY"]}],
+		"maxPrices": [
+			{"route": "SCL-MAD", "amount": 900, "currency": "EUR"},
+			{"route": "*", "amount": 1000, "currency": "EUR"},
+			{"route": "*", "amount": 500000, "currency": "CLP"}
+		],
+		"preferredCarriers": ["LA", "IB"]
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	casos := []struct {
+		nombre       string
+		oferta       FlightOffer
+		infracciones []string
+	}{
+		{"cumple", ofertaPolitica("IB6830", "812.34", "EUR", "PT5H", "ECONOMY"), nil},
+		{"business bajo la duracion", ofertaPolitica("IB6830", "812.34", "EUR", "PT5H59M", "BUSINESS"), []string{
+			"Cabina BUSINESS en el segmento IB6830 SCL-MAD (viajes de menos de 6h: ECONOMY)",
+		}},
+		{"business en la duracion", ofertaPolitica("IB6830", "812.34", "EUR", "PT6H", "BUSINESS"), nil},
+		{"business sobre la duracion", ofertaPolitica("IB6830", "812.34", "EUR", "P1DT2H", "BUSINESS"), nil},
+		{"business sin duracion", ofertaPolitica("IB6830", "812.34", "EUR", "PT", "BUSINESS"), []string{
+			"Cabina BUSINESS en el segmento IB6830 SCL-MAD (viajes de menos de 6h: ECONOMY)",
+		}},
+		{"sobre la ruta exacta", ofertaPolitica("IB6830", "950.00", "EUR", "PT5H", "ECONOMY"), []string{
+			"Precio 950.00 EUR sobre el máximo de 900 EUR para SCL-MAD",
+		}},
+		{"sobre la ruta exacta y la general", ofertaPolitica("IB6830", "1200.00", "EUR", "PT5H", "ECONOMY"), []string{
+			"Precio 1200.00 EUR sobre el máximo de 900 EUR para SCL-MAD",
+			"Precio 1200.00 EUR sobre el máximo de 1000 EUR para *",
+		}},
+		{"otra moneda", ofertaPolitica("IB6830", "950.00", "USD", "PT5H", "ECONOMY"), nil},
+		{"sobre la ruta general en otra moneda", ofertaPolitica("IB6830", "600000", "CLP", "PT5H", "ECONOMY"), []string{
+			"Precio 600000 CLP sobre el máximo de 500000 CLP para *",
+		}},
+		{"aerolinea no preferida", ofertaPolitica("UX46", "812.34", "EUR", "PT5H", "ECONOMY"), []string{
+			"Aerolínea UX no preferida (segmento UX46)",
+		}},
+	}
+	for _, caso := range casos {
+		cumplimiento := politica.evaluar(caso.oferta)
+		if !reflect.DeepEqual(cumplimiento.Infracciones, caso.infracciones) {
+			t.Errorf("%s: infracciones = %q, se esperaba %q", caso.nombre, cumplimiento.Infracciones, caso.infracciones)
+		}
+		if cumplimiento.Cumple != (len(caso.infracciones) == 0) {
+			t.Errorf("%s: cumple = %v", caso.nombre, cumplimiento.Cumple)
+		}
+	}
+}
+
+func TestEvaluarSinPolitica(t *testing.T) {
+	var politica *PoliticaViajes
+	if cumplimiento := politica.evaluar(ofertaPolitica("UX46", "99999", "EUR", "PT1H", "FIRST")); !cumplimiento.Cumple {
+		t.Errorf("sin política la oferta debe cumplir: %+v", cumplimiento)
+	}
+}
+
+func TestDuracionISO(t *testing.T) {
+	casos := []struct {
+		valor    string
+		duracion time.Duration
+		ok       bool
+	}{
+		{"PT5H30M", 5*time.Hour + 30*time.Minute, true},
+		{"PT45M", 45 * time.Minute, true},
+		{"P1DT2H", 26 * time.Hour, true},
+		{"P2D", 48 * time.Hour, true},
+		{"PT", 0, false},
+		{"P", 0, false},
+		{"", 0, false},
+		{"5H30M", 0, false},
+	}
+	for _, caso := range casos {
+		duracion, ok := duracionISO(caso.valor)
+		if duracion != caso.duracion || ok != caso.ok {
+			t.Errorf("duracionISO(%q) = %v, %v; se esperaba %v, %v", caso.valor, duracion, ok, caso.duracion, caso.ok)
+		}
+	}
+}
+
+func TestCargarPolitica(t *testing.T) {
+	if politica, err := cargarPolitica(""); politica != nil || err != nil {
+		t.Errorf("sin archivo = %v, %v; se esperaba sin política", politica, err)
+	}
+	if _, err := cargarPolitica(filepath.Join(t.TempDir(), "no-existe.json")); err == nil {
+		t.Error("se esperaba error con un archivo que no existe")
+	}
+
+	casos := []struct {
+		nombre    string
+		contenido string
+		error     string
+	}{
+		{"valida", `{"cabinRules": [{"underDuration": "6h", "allowedCabins": ["ECONOMY"]}], "maxPrices": [{"route": "*", "amount": 1000, "currency": "EUR"}]}`, ""},
+		{"json no valido", `{"cabinRules": `, "política de viajes no válida"},
+		{"duracion no valida", `{"cabinRules": [{"underDuration": "PT6H", "allowedCabins": ["ECONOMY"]}]}`, "underDuration no válido"},
+		{"duracion cero", `{"cabinRules": [{"underDuration": "0s", "allowedCabins": ["ECONOMY"]}]}`, "underDuration no válido"},
+		{"regla sin cabinas", `{"cabinRules": [{"underDuration": "6h"}]}`, "no indica cabinas"},
+		{"precio sin moneda", `{"maxPrices": [{"route": "SCL-LIM", "amount": 300000}]}`, "precio máximo incompleto"},
+		{"precio sin ruta", `{"maxPrices": [{"amount": 300000, "currency": "CLP"}]}`, "precio máximo incompleto"},
+	}
+	for _, caso := range casos {
+		politica, err := politicaPrueba(t, caso.contenido)
+		if caso.error == "" {
+			if err != nil {
+				t.Errorf("%s: %v", caso.nombre, err)
+			} else if politica.Cabinas[0].duracion != 6*time.Hour {
+				t.Errorf("%s: duración = %v", caso.nombre, politica.Cabinas[0].duracion)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), caso.error) {
+			t.Errorf("%s: error = %v, se esperaba %q", caso.nombre, err, caso.error)
+		}
+	}
+}
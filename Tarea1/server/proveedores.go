@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Proveedor de ofertas de vuelo. Cada proveedor se consulta en paralelo con
+// su propio plazo, y sus resultados se combinan en una sola lista
+type Proveedor interface {
+	Nombre() string
+	Buscar(ctx context.Context, parametros ParametrosBusqueda) ([]FlightOffer, error)
+}
+
+// Proveedor que consulta la API de Amadeus
+type proveedorAmadeus struct{}
+
+func (proveedorAmadeus) Nombre() string { return "amadeus" }
+
+func (proveedorAmadeus) Buscar(ctx context.Context, parametros ParametrosBusqueda) ([]FlightOffer, error) {
+	return consultarVuelos(ctx, parametros)
+}
+
+// Proveedores consultados en cada busqueda
+var proveedores = []Proveedor{proveedorAmadeus{}}
+
+// Resultado combinado de todos los proveedores. Advertencias indica los
+// proveedores que fallaron o no respondieron a tiempo
+type ResultadoBusqueda struct {
+	Ofertas      []FlightOffer `json:"data"`
+	Advertencias []string      `json:"warnings,omitempty"`
+}
+
+// Consulta todos los proveedores en paralelo y combina sus ofertas. Si se
+// indica avisar, se llama (desde el goroutine que invoca) cada vez que un
+// proveedor responde. Solo se devuelve error si ningun proveedor respondio
+func buscarEnProveedores(ctx context.Context, parametros ParametrosBusqueda, avisar func(proveedor string, ofertas []FlightOffer, err error)) (ResultadoBusqueda, error) {
+	type respuesta struct {
+		indice  int
+		ofertas []FlightOffer
+		err     error
+	}
+	respuestas := make(chan respuesta, len(proveedores))
+
+	for i, proveedor := range proveedores {
+		go func(i int, proveedor Proveedor) {
+			ctx, cancelar := context.WithTimeout(ctx, config.TiempoProveedor)
+			defer cancelar()
+			ofertas, err := proveedor.Buscar(ctx, parametros)
+			// Se copian las ofertas porque pueden venir de la cache compartida
+			ofertas = append([]FlightOffer(nil), ofertas...)
+			for i := range ofertas {
+				ofertas[i].Proveedor = proveedor.Nombre()
+				ofertas[i].IdProveedor = ofertas[i].Id
+			}
+			anotarOfertas(ctx, ofertas)
+			respuestas <- respuesta{i, ofertas, err}
+		}(i, proveedor)
+	}
+
+	// Las listas se combinan en el orden de registro de los proveedores,
+	// no en el de llegada, para que el resultado no dependa de la latencia
+	listas := make([][]FlightOffer, len(proveedores))
+	errores := make([]error, len(proveedores))
+	for range proveedores {
+		r := <-respuestas
+		listas[r.indice], errores[r.indice] = r.ofertas, r.err
+		if avisar != nil {
+			avisar(proveedores[r.indice].Nombre(), r.ofertas, r.err)
+		}
+	}
+
+	var resultado ResultadoBusqueda
+	respondieron := 0
+	for i, err := range errores {
+		nombre := proveedores[i].Nombre()
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			resultado.Advertencias = append(resultado.Advertencias, fmt.Sprintf("%s: no respondió dentro de %s", nombre, config.TiempoProveedor))
+		case err != nil:
+			resultado.Advertencias = append(resultado.Advertencias, fmt.Sprintf("%s: %v", nombre, err))
+		default:
+			respondieron++
+		}
+	}
+	if respondieron == 0 && len(proveedores) > 0 {
+		return resultado, fmt.Errorf("ningún proveedor respondió: %s", strings.Join(resultado.Advertencias, "; "))
+	}
+
+	resultado.Ofertas = combinarOfertas(listas...)
+	return resultado, nil
+}
+
+// Une las listas de ofertas dejando solo la mas barata de cada itinerario.
+// Se conserva el orden en que aparece cada itinerario por primera vez
+func combinarOfertas(listas ...[]FlightOffer) []FlightOffer {
+	var combinadas []FlightOffer
+	posiciones := make(map[string]int)
+	for _, lista := range listas {
+		for _, oferta := range lista {
+			clave := claveItinerario(oferta)
+			i, repetida := posiciones[clave]
+			if !repetida {
+				posiciones[clave] = len(combinadas)
+				combinadas = append(combinadas, oferta)
+				continue
+			}
+			if precioOferta(oferta) < precioOferta(combinadas[i]) {
+				combinadas[i] = oferta
+			}
+		}
+	}
+
+	// Cada proveedor numera sus ofertas desde 1, por lo que se renumeran
+	// solo si hay ids repetidos. El id original queda en IdProveedor y se
+	// restaura al cotizar o reservar (ver quitarAnotaciones)
+	ids := make(map[string]bool)
+	for _, oferta := range combinadas {
+		ids[oferta.Id] = true
+	}
+	if len(ids) < len(combinadas) {
+		for i := range combinadas {
+			combinadas[i].Id = strconv.Itoa(i + 1)
+		}
+	}
+	return combinadas
+}
+
+// Identifica un itinerario por sus vuelos: aerolinea, numero y hora de
+// salida de cada segmento
+func claveItinerario(oferta FlightOffer) string {
+	var partes []string
+	for _, itinerario := range oferta.Itineraries {
+		var segmentos []string
+		for _, segmento := range itinerario.Segments {
+			segmentos = append(segmentos, segmento.CarrierCode+segmento.Number+"@"+segmento.Departure.At)
+		}
+		partes = append(partes, strings.Join(segmentos, ","))
+	}
+	return strings.Join(partes, "|")
+}
+
+// Precio total de la oferta. Las ofertas sin precio valido quedan al final
+func precioOferta(oferta FlightOffer) float64 {
+	precio, err := strconv.ParseFloat(oferta.Price.GrandTotal, 64)
+	if err != nil {
+		return math.MaxFloat64
+	}
+	return precio
+}
+
+// Agrega a las ofertas los datos que calcula el servidor: emisiones y
+// cumplimiento de la politica de viajes
+func anotarOfertas(ctx context.Context, ofertas []FlightOffer) {
+	agregarEmisiones(ofertas)
+	agregarPolitica(ctx, ofertas)
+}
+
+// Quita de las ofertas los datos agregados por el servidor antes de
+// enviarlas al proveedor, que no conoce esos campos, y les devuelve el id
+// que les dio el proveedor. La cotizacion y la reserva siempre van a
+// Amadeus, por eso devuelve un error si una oferta es de otro proveedor
+func quitarAnotaciones(ofertas []FlightOffer) error {
+	for _, oferta := range ofertas {
+		if err := validarProveedorOferta(oferta.Id, oferta.Proveedor); err != nil {
+			return err
+		}
+	}
+	for i := range ofertas {
+		if ofertas[i].IdProveedor != "" {
+			ofertas[i].Id = ofertas[i].IdProveedor
+		}
+		ofertas[i].Emisiones = nil
+		ofertas[i].Politica = nil
+		ofertas[i].Proveedor = ""
+		ofertas[i].IdProveedor = ""
+	}
+	return nil
+}
+
+// Igual que quitarAnotaciones, para los cuerpos que se reenvian sin
+// deserializar ({"data": {"flightOffers": [...]}})
+func quitarAnotacionesJSON(datos map[string]interface{}) error {
+	data, _ := datos["data"].(map[string]interface{})
+	ofertas, _ := data["flightOffers"].([]interface{})
+	for _, oferta := range ofertas {
+		campos, _ := oferta.(map[string]interface{})
+		id, _ := campos["id"].(string)
+		proveedor, _ := campos["provider"].(string)
+		if err := validarProveedorOferta(id, proveedor); err != nil {
+			return err
+		}
+	}
+	for _, oferta := range ofertas {
+		if campos, ok := oferta.(map[string]interface{}); ok {
+			if id, ok := campos["providerOfferId"].(string); ok && id != "" {
+				campos["id"] = id
+			}
+			delete(campos, "co2Emissions")
+			delete(campos, "policy")
+			delete(campos, "provider")
+			delete(campos, "providerOfferId")
+		}
+	}
+	return nil
+}
+
+// Las ofertas sin proveedor vienen de clientes anteriores a la combinacion
+// de proveedores y son de Amadeus
+func validarProveedorOferta(id, proveedor string) error {
+	if proveedor != "" && proveedor != (proveedorAmadeus{}).Nombre() {
+		return fmt.Errorf("la oferta %s es de %s; por ahora solo se pueden cotizar y reservar ofertas de %s", id, proveedor, (proveedorAmadeus{}).Nombre())
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Proveedor para los tests: devuelve sus ofertas despues de la espera
+// indicada, o el error si lo tiene
+type proveedorPrueba struct {
+	nombre  string
+	ofertas []FlightOffer
+	espera  time.Duration
+	err     error
+}
+
+func (p proveedorPrueba) Nombre() string { return p.nombre }
+
+func (p proveedorPrueba) Buscar(ctx context.Context, parametros ParametrosBusqueda) ([]FlightOffer, error) {
+	select {
+	case <-time.After(p.espera):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return p.ofertas, p.err
+}
+
+// Oferta de un solo segmento con el id, vuelo y precio indicados
+func ofertaPrueba(id, vuelo, total string) FlightOffer {
+	oferta := FlightOffer{Id: id, Price: Price{Currency: "EUR", GrandTotal: total}}
+	oferta.Itineraries = []Itinerary{{Segments: []Segment{{
+		CarrierCode: vuelo[:2],
+		Number:      vuelo[2:],
+		Departure:   Departure{IataCode: "SCL", At: "2030-03-10T23:55:00"},
+		Arrival:     Arrival{IataCode: "MAD", At: "2030-03-11T16:30:00"},
+	}}}}
+	return oferta
+}
+
+// Reemplaza los proveedores registrados y su plazo durante el test
+func usarProveedores(t *testing.T, plazo time.Duration, lista ...Proveedor) {
+	t.Helper()
+	anteriores, anteriorConfig := proveedores, config
+	t.Cleanup(func() { proveedores, config = anteriores, anteriorConfig })
+	proveedores = lista
+	config = configuracionPorDefecto()
+	config.TiempoProveedor = plazo
+}
+
+func TestBuscarEnProveedoresCombinaYDeduplica(t *testing.T) {
+	usarProveedores(t, time.Second,
+		proveedorPrueba{nombre: "amadeus", ofertas: []FlightOffer{
+			ofertaPrueba("1", "IB6830", "812.34"),
+			ofertaPrueba("2", "LA704", "905.10"),
+		}},
+		proveedorPrueba{nombre: "otro", ofertas: []FlightOffer{
+			// El mismo vuelo que la oferta 2 de amadeus, mas barato
+			ofertaPrueba("1", "LA704", "880.00"),
+			ofertaPrueba("2", "UX46", "700.00"),
+		}},
+	)
+
+	resultado, err := buscarEnProveedores(context.Background(), ParametrosBusqueda{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resultado.Advertencias) != 0 {
+		t.Errorf("advertencias = %v", resultado.Advertencias)
+	}
+
+	esperadas := []struct {
+		id, vuelo, total, proveedor, idProveedor string
+	}{
+		{"1", "IB6830", "812.34", "amadeus", "1"},
+		{"2", "LA704", "880.00", "otro", "1"},
+		{"3", "UX46", "700.00", "otro", "2"},
+	}
+	if len(resultado.Ofertas) != len(esperadas) {
+		t.Fatalf("ofertas = %d, se esperaban %d", len(resultado.Ofertas), len(esperadas))
+	}
+	for i, esperada := range esperadas {
+		oferta := resultado.Ofertas[i]
+		vuelo := oferta.Itineraries[0].Segments[0].CarrierCode + oferta.Itineraries[0].Segments[0].Number
+		if oferta.Id != esperada.id || vuelo != esperada.vuelo || oferta.Price.GrandTotal != esperada.total ||
+			oferta.Proveedor != esperada.proveedor || oferta.IdProveedor != esperada.idProveedor {
+			t.Errorf("oferta %d = %s %s %s de %s (%s), se esperaba %+v", i, oferta.Id, vuelo, oferta.Price.GrandTotal, oferta.Proveedor, oferta.IdProveedor, esperada)
+		}
+	}
+
+	// Las ofertas de otro proveedor no se pueden cotizar ni reservar
+	if err := quitarAnotaciones(resultado.Ofertas); err == nil {
+		t.Error("se aceptaron ofertas de otro proveedor")
+	}
+	// Las de Amadeus recuperan su id al cotizar o reservar
+	amadeus := resultado.Ofertas[:1]
+	if err := quitarAnotaciones(amadeus); err != nil {
+		t.Fatal(err)
+	}
+	if oferta := amadeus[0]; oferta.Id != "1" || oferta.Proveedor != "" || oferta.IdProveedor != "" {
+		t.Errorf("oferta sin anotaciones = %q (%q, %q), se esperaba el id 1", oferta.Id, oferta.Proveedor, oferta.IdProveedor)
+	}
+}
+
+func TestQuitarAnotacionesJSONRestauraElId(t *testing.T) {
+	datos := map[string]interface{}{"data": map[string]interface{}{"flightOffers": []interface{}{
+		map[string]interface{}{"id": "3", "provider": "amadeus", "providerOfferId": "2", "policy": map[string]interface{}{}},
+		map[string]interface{}{"id": "1"},
+	}}}
+	if err := quitarAnotacionesJSON(datos); err != nil {
+		t.Fatal(err)
+	}
+
+	ofertas := datos["data"].(map[string]interface{})["flightOffers"].([]interface{})
+	primera, segunda := ofertas[0].(map[string]interface{}), ofertas[1].(map[string]interface{})
+	if primera["id"] != "2" || len(primera) != 1 {
+		t.Errorf("primera oferta = %v, se esperaba solo el id 2", primera)
+	}
+	if segunda["id"] != "1" {
+		t.Errorf("segunda oferta = %v", segunda)
+	}
+
+	otro := map[string]interface{}{"data": map[string]interface{}{"flightOffers": []interface{}{
+		map[string]interface{}{"id": "3", "provider": "otro", "providerOfferId": "2"},
+	}}}
+	if err := quitarAnotacionesJSON(otro); err == nil {
+		t.Error("se aceptó una oferta de otro proveedor")
+	}
+}
+
+func TestBuscarEnProveedoresAvisaDelPlazo(t *testing.T) {
+	usarProveedores(t, 50*time.Millisecond,
+		proveedorPrueba{nombre: "amadeus", ofertas: []FlightOffer{ofertaPrueba("1", "IB6830", "812.34")}},
+		proveedorPrueba{nombre: "lento", espera: time.Second, ofertas: []FlightOffer{ofertaPrueba("1", "UX46", "700.00")}},
+	)
+
+	var avisados []string
+	resultado, err := buscarEnProveedores(context.Background(), ParametrosBusqueda{}, func(proveedor string, ofertas []FlightOffer, err error) {
+		avisados = append(avisados, proveedor)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resultado.Ofertas) != 1 || resultado.Ofertas[0].Proveedor != "amadeus" {
+		t.Errorf("ofertas = %+v, se esperaba solo la de amadeus", resultado.Ofertas)
+	}
+	if len(resultado.Advertencias) != 1 || !strings.HasPrefix(resultado.Advertencias[0], "lento: no respondió dentro de 50ms") {
+		t.Errorf("advertencias = %v", resultado.Advertencias)
+	}
+	if strings.Join(avisados, ",") != "amadeus,lento" {
+		t.Errorf("proveedores avisados = %v, se esperaba amadeus y luego lento", avisados)
+	}
+}
+
+func TestBuscarEnProveedoresSinRespuestas(t *testing.T) {
+	usarProveedores(t, time.Second,
+		proveedorPrueba{nombre: "amadeus", err: errors.New("Amadeus respondió 500")},
+		proveedorPrueba{nombre: "otro", err: errors.New("sin conexión")},
+	)
+
+	resultado, err := buscarEnProveedores(context.Background(), ParametrosBusqueda{}, nil)
+	if err == nil {
+		t.Fatal("se esperaba un error si ningún proveedor responde")
+	}
+	if len(resultado.Advertencias) != 2 {
+		t.Errorf("advertencias = %v", resultado.Advertencias)
+	}
+}
+
+func TestCotizarYReservarRechazanOtroProveedor(t *testing.T) {
+	r := prepararReproduccion(t)
+
+	cuerpo := `{"data":{"type":"flight-offers-pricing","flightOffers":[{"id":"3","provider":"otro","providerOfferId":"1"}]}}`
+	for _, ruta := range []string{"/pricing", "/booking"} {
+		req := httptest.NewRequest(http.MethodPost, ruta, strings.NewReader(cuerpo))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "otro") {
+			t.Errorf("%s: %d %s, se esperaba 400", ruta, w.Code, w.Body)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pdf/fpdf"
+)
+
+//go:embed itinerario.html
+var plantillaItinerarioHTML string
+
+var plantillaItinerario = template.Must(template.New("itinerario").Parse(plantillaItinerarioHTML))
+
+// Datos del itinerario listos para ser presentados en HTML o PDF
+type Recibo struct {
+	Id         string
+	Referencia string
+	Emision    string
+	Pasajeros  []ReciboPasajero
+	Segmentos  []ReciboSegmento
+	Tarifas    []ReciboTarifa
+	Equipaje   []ReciboEquipaje
+}
+
+type ReciboPasajero struct {
+	Id              string
+	Nombre          string
+	FechaNacimiento string
+	Correo          string
+	Tipo            string
+}
+
+type ReciboSegmento struct {
+	Vuelo   string
+	Origen  string
+	Destino string
+	Salida  string
+	Llegada string
+	Avion   string
+	Cabina  string
+}
+
+type ReciboCargo struct {
+	Descripcion string
+	Monto       string
+}
+
+type ReciboTarifa struct {
+	Oferta     string
+	Moneda     string
+	Base       string
+	Cargos     []ReciboCargo
+	Total      string
+	GrandTotal string
+}
+
+type ReciboEquipaje struct {
+	Pasajero  string
+	Segmento  string
+	Incluido  string
+	Adicional string
+}
+
+func exportarItinerarioHTML(c *gin.Context) {
+	recibo, ok := cargarRecibo(c)
+	if !ok {
+		return
+	}
+
+	var html bytes.Buffer
+	if err := plantillaItinerario.Execute(&html, recibo); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error al generar el itinerario", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al generar el itinerario"})
+		return
+	}
+
+	c.Data(http.StatusOK, "text/html; charset=utf-8", html.Bytes())
+}
+
+func exportarItinerarioPDF(c *gin.Context) {
+	recibo, ok := cargarRecibo(c)
+	if !ok {
+		return
+	}
+
+	pdf, err := generarPDF(recibo)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error al generar el PDF", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al generar el PDF"})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="itinerario.pdf"`)
+	c.Data(http.StatusOK, "application/pdf", pdf)
+}
+
+// Busca la reserva indicada en la ruta y construye su recibo. Si falla,
+// responde al cliente y devuelve false
+func cargarRecibo(c *gin.Context) (Recibo, bool) {
+	reserva, err := buscarReservaGuardada(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, errReservaNoEncontrada) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reserva no encontrada"})
+		return Recibo{}, false
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error al buscar la reserva", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al buscar la reserva"})
+		return Recibo{}, false
+	}
+	return construirRecibo(reserva), true
+}
+
+func construirRecibo(reserva Booking) Recibo {
+	recibo := Recibo{Id: reserva.Data.Id}
+
+	var referencias []string
+	for _, registro := range reserva.Data.AssociatedRecords {
+		referencias = append(referencias, registro.Reference)
+		if recibo.Emision == "" {
+			recibo.Emision = registro.CreationDate
+		}
+	}
+	recibo.Referencia = strings.Join(referencias, ", ")
+
+	tipos := make(map[string]string)
+	for _, offer := range reserva.Data.FlightOffers {
+		for _, pricing := range offer.TravelerPricings {
+			tipos[pricing.TravelerId] = pricing.TravelerType
+		}
+	}
+
+	nombres := make(map[string]string)
+	for _, traveler := range reserva.Data.Travelers {
+		nombre := traveler.Name.FirstName + " " + traveler.Name.LastName
+		nombres[traveler.Id] = nombre
+		recibo.Pasajeros = append(recibo.Pasajeros, ReciboPasajero{
+			Id:              traveler.Id,
+			Nombre:          nombre,
+			FechaNacimiento: traveler.DateOfBirth,
+			Correo:          traveler.Contact.EmailAddress,
+			Tipo:            tipos[traveler.Id],
+		})
+	}
+
+	for _, offer := range reserva.Data.FlightOffers {
+		cabinas := make(map[string]string)
+		for _, pricing := range offer.TravelerPricings {
+			for _, detalle := range pricing.FareDetailsBySegment {
+				cabinas[detalle.SegmentId] = detalle.Cabin
+			}
+		}
+
+		for _, itinerario := range offer.Itineraries {
+			for _, segmento := range itinerario.Segments {
+				recibo.Segmentos = append(recibo.Segmentos, ReciboSegmento{
+					Vuelo:   segmento.CarrierCode + segmento.Number,
+					Origen:  nombreAeropuerto(segmento.Departure.IataCode),
+					Destino: nombreAeropuerto(segmento.Arrival.IataCode),
+					Salida:  formatearFecha(segmento.Departure.At),
+					Llegada: formatearFecha(segmento.Arrival.At),
+					Avion:   segmento.Aircraft.Code,
+					Cabina:  cabinas[segmento.Id],
+				})
+			}
+		}
+
+		tarifa := ReciboTarifa{
+			Oferta:     offer.Id,
+			Moneda:     offer.Price.Currency,
+			Base:       offer.Price.Base,
+			Total:      offer.Price.Total,
+			GrandTotal: offer.Price.GrandTotal,
+		}
+		for _, fee := range offer.Price.Fees {
+			if monto, err := strconv.ParseFloat(fee.Amount, 64); err == nil && monto == 0 {
+				continue
+			}
+			tarifa.Cargos = append(tarifa.Cargos, ReciboCargo{Descripcion: "Cargo " + fee.Type, Monto: fee.Amount})
+		}
+		for _, servicio := range offer.Price.AdditionalServices {
+			tarifa.Cargos = append(tarifa.Cargos, ReciboCargo{Descripcion: "Servicio " + servicio.Type, Monto: servicio.Amount})
+		}
+		recibo.Tarifas = append(recibo.Tarifas, tarifa)
+
+		for _, pricing := range offer.TravelerPricings {
+			for _, detalle := range pricing.FareDetailsBySegment {
+				equipaje := ReciboEquipaje{
+					Pasajero: nombres[pricing.TravelerId],
+					Segmento: rutaSegmento(offer, detalle.SegmentId),
+					Incluido: describirMaletas(detalle.IncludedCheckedBags.Quantity, detalle.IncludedCheckedBags.Weight, detalle.IncludedCheckedBags.WeightUnit),
+				}
+				if detalle.AdditionalServices != nil && detalle.AdditionalServices.ChargeableCheckedBags != nil {
+					bolsas := detalle.AdditionalServices.ChargeableCheckedBags
+					equipaje.Adicional = describirMaletas(bolsas.Quantity, bolsas.Weight, bolsas.WeightUnit)
+				}
+				recibo.Equipaje = append(recibo.Equipaje, equipaje)
+			}
+		}
+	}
+
+	return recibo
+}
+
+func generarPDF(recibo Recibo) ([]byte, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	// Las fuentes base de PDF usan cp1252, por lo que se traducen los textos
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetTitle("Itinerario "+recibo.Referencia, true)
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 10, tr("goTravel - Itinerario de viaje"), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(0, 6, tr("Código de reserva: "+recibo.Referencia), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 6, tr("ID de reserva: "+recibo.Id), "", 1, "L", false, 0, "")
+	if recibo.Emision != "" {
+		pdf.CellFormat(0, 6, tr("Fecha de emisión: "+recibo.Emision), "", 1, "L", false, 0, "")
+	}
+
+	tabla := func(titulo string, encabezado []string, anchos []float64, filas [][]string) {
+		pdf.Ln(4)
+		pdf.SetFont("Helvetica", "B", 12)
+		pdf.CellFormat(0, 8, tr(titulo), "", 1, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "B", 8)
+		for i, columna := range encabezado {
+			pdf.CellFormat(anchos[i], 6, tr(columna), "1", 0, "L", false, 0, "")
+		}
+		pdf.Ln(-1)
+		pdf.SetFont("Helvetica", "", 8)
+		for _, fila := range filas {
+			for i, valor := range fila {
+				pdf.CellFormat(anchos[i], 6, tr(valor), "1", 0, "L", false, 0, "")
+			}
+			pdf.Ln(-1)
+		}
+	}
+
+	var filas [][]string
+	for _, pasajero := range recibo.Pasajeros {
+		filas = append(filas, []string{pasajero.Nombre, pasajero.Tipo, pasajero.FechaNacimiento, pasajero.Correo})
+	}
+	tabla("Pasajeros", []string{"NOMBRE", "TIPO", "NACIMIENTO", "CORREO"}, []float64{60, 25, 30, 75}, filas)
+
+	filas = nil
+	for _, segmento := range recibo.Segmentos {
+		filas = append(filas, []string{segmento.Vuelo, segmento.Origen, segmento.Destino, segmento.Salida, segmento.Llegada, segmento.Avion, segmento.Cabina})
+	}
+	tabla("Vuelos", []string{"VUELO", "ORIGEN", "DESTINO", "SALIDA", "LLEGADA", "AVIÓN", "CABINA"}, []float64{15, 47, 47, 24, 24, 16, 17}, filas)
+
+	filas = nil
+	for _, equipaje := range recibo.Equipaje {
+		filas = append(filas, []string{equipaje.Pasajero, equipaje.Segmento, equipaje.Incluido, equipaje.Adicional})
+	}
+	tabla("Equipaje", []string{"PASAJERO", "SEGMENTO", "INCLUIDO", "ADICIONAL"}, []float64{60, 30, 50, 50}, filas)
+
+	for _, tarifa := range recibo.Tarifas {
+		filas = [][]string{{"Tarifa base", tarifa.Base + " " + tarifa.Moneda}}
+		for _, cargo := range tarifa.Cargos {
+			filas = append(filas, []string{cargo.Descripcion, cargo.Monto + " " + tarifa.Moneda})
+		}
+		filas = append(filas,
+			[]string{"Total", tarifa.Total + " " + tarifa.Moneda},
+			[]string{"Total final", tarifa.GrandTotal + " " + tarifa.Moneda},
+		)
+		tabla("Tarifa (oferta "+tarifa.Oferta+")", []string{"CONCEPTO", "MONTO"}, []float64{100, 90}, filas)
+	}
+
+	var salida bytes.Buffer
+	if err := pdf.Output(&salida); err != nil {
+		return nil, err
+	}
+	return salida.Bytes(), nil
+}
+
+func describirMaletas(cantidad int, peso int, unidad string) string {
+	if peso > 0 {
+		return fmt.Sprintf("%d %s", peso, unidad)
+	}
+	if cantidad == 1 {
+		return "1 maleta"
+	}
+	return fmt.Sprintf("%d maletas", cantidad)
+}
+
+// Muestra una fecha de Amadeus en un formato legible
+func formatearFecha(fecha string) string {
+	parsedTime, err := time.Parse(formatoAmadeus, fecha)
+	if err != nil {
+		return fecha
+	}
+	return parsedTime.Format("02-01-2006 15:04")
+}
+
+// Devuelve el origen y destino del segmento indicado dentro de la oferta
+func rutaSegmento(offer FlightOffer, segmentId string) string {
+	for _, itinerario := range offer.Itineraries {
+		for _, segmento := range itinerario.Segments {
+			if segmento.Id == segmentId {
+				return segmento.Departure.IataCode + "-" + segmento.Arrival.IataCode
+			}
+		}
+	}
+	return ""
+}
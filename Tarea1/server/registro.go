@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Registro del servidor: una linea JSON por evento, con el id de la
+// solicitud y la agencia tomados del contexto. Los datos personales se
+// reemplazan antes de escribirse, sin importar quien registre el evento
+
+// Claves que se redactan en los registros, ademas de los campos sensibles
+// de la auditoria
+var clavesSensiblesRegistro = map[string]bool{
+	"nombre":    true,
+	"apellido":  true,
+	"pasajero":  true,
+	"email":     true,
+	"correo":    true,
+	"telefono":  true,
+	"phone":     true,
+	"documento": true,
+	"pasaporte": true,
+}
+
+// Datos personales reconocibles dentro de un texto: correos, telefonos con
+// prefijo internacional, RUT, numeros largos (documentos o telefonos sin
+// prefijo) y documentos alfanumericos como pasaportes (AB1234567) o DNI
+// (12345678Z). Los numeros de vuelo (IB6830) son mas cortos y no se tocan
+var patronesSensibles = []*regexp.Regexp{
+	regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	regexp.MustCompile(`\+\d[\d\s-]{6,}\d`),
+	regexp.MustCompile(`\b\d{1,2}\.\d{3}\.\d{3}-[\dkK]\b`),
+	regexp.MustCompile(`\b\d{7,8}-[\dkK]\b`),
+	regexp.MustCompile(`\b\d{8,}\b`),
+	regexp.MustCompile(`\b[A-Z]{1,3}\d{6,9}\b`),
+	regexp.MustCompile(`\b\d{7,8}[A-Z]\b`),
+}
+
+// Configura el registro por defecto (slog) con el nivel indicado: debug,
+// info, warn o error
+func configurarRegistro(nivel string) error {
+	var nivelRegistro slog.Level
+	if nivel != "" {
+		if err := nivelRegistro.UnmarshalText([]byte(nivel)); err != nil {
+			return fmt.Errorf("LOG_LEVEL no válido: %q", nivel)
+		}
+	}
+	manejador := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: nivelRegistro})
+	slog.SetDefault(slog.New(manejadorRegistro{manejador}))
+
+	// En modo debug gin informa cada ruta registrada; se lleva al mismo
+	// registro en vez de escribirla como texto
+	gin.DebugPrintRouteFunc = func(metodo, ruta, handler string, _ int) {
+		slog.Debug("Ruta registrada", "metodo", metodo, "ruta", ruta, "handler", handler)
+	}
+	return nil
+}
+
+// Manejador que agrega el contexto de la solicitud y redacta los datos
+// personales de cada registro
+type manejadorRegistro struct {
+	slog.Handler
+}
+
+func (m manejadorRegistro) Handle(ctx context.Context, r slog.Record) error {
+	redactado := slog.NewRecord(r.Time, r.Level, redactarTexto(r.Message), r.PC)
+	if id := idDeSolicitud(ctx); id != "" {
+		redactado.AddAttrs(slog.String("idSolicitud", id))
+	}
+	if agencia := idAgencia(ctx); agencia != "" {
+		redactado.AddAttrs(slog.String("agencia", agencia))
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		redactado.AddAttrs(redactarAtributo(a))
+		return true
+	})
+	return m.Handler.Handle(ctx, redactado)
+}
+
+func (m manejadorRegistro) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redactados := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redactados[i] = redactarAtributo(a)
+	}
+	return manejadorRegistro{m.Handler.WithAttrs(redactados)}
+}
+
+func (m manejadorRegistro) WithGroup(nombre string) slog.Handler {
+	return manejadorRegistro{m.Handler.WithGroup(nombre)}
+}
+
+func claveSensible(clave string) bool {
+	return camposSensibles[clave] || clavesSensiblesRegistro[strings.ToLower(clave)]
+}
+
+func redactarAtributo(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	if claveSensible(a.Key) {
+		return slog.String(a.Key, textoRedactado)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, redactarTexto(a.Value.String()))
+	case slog.KindGroup:
+		grupo := a.Value.Group()
+		redactados := make([]any, len(grupo))
+		for i, hijo := range grupo {
+			redactados[i] = redactarAtributo(hijo)
+		}
+		return slog.Group(a.Key, redactados...)
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			return slog.String(a.Key, redactarTexto(v.Error()))
+		case fmt.Stringer:
+			return slog.String(a.Key, redactarTexto(v.String()))
+		}
+		// Estructuras y mapas se revisan como JSON, igual que la auditoria
+		contenido, err := json.Marshal(a.Value.Any())
+		if err != nil {
+			return slog.String(a.Key, textoRedactado)
+		}
+		var datos interface{}
+		if err := json.Unmarshal(contenido, &datos); err != nil {
+			return slog.String(a.Key, textoRedactado)
+		}
+		return slog.Any(a.Key, redactarTextos(redactarClaves(redactar(datos, ""))))
+	}
+	return a
+}
+
+// Reemplaza las claves sensibles de los registros en un JSON generico
+func redactarClaves(valor interface{}) interface{} {
+	switch v := valor.(type) {
+	case map[string]interface{}:
+		for clave, hijo := range v {
+			if claveSensible(clave) {
+				v[clave] = ocultarTextos(hijo)
+				continue
+			}
+			v[clave] = redactarClaves(hijo)
+		}
+	case []interface{}:
+		for i, hijo := range v {
+			v[i] = redactarClaves(hijo)
+		}
+	}
+	return valor
+}
+
+// Aplica redactarTexto a cada texto de un JSON generico
+func redactarTextos(valor interface{}) interface{} {
+	switch v := valor.(type) {
+	case string:
+		return redactarTexto(v)
+	case map[string]interface{}:
+		for clave, hijo := range v {
+			v[clave] = redactarTextos(hijo)
+		}
+	case []interface{}:
+		for i, hijo := range v {
+			v[i] = redactarTextos(hijo)
+		}
+	}
+	return valor
+}
+
+// Quita los datos personales de un cuerpo JSON con las mismas reglas de
+// los registros. Si no es JSON se trata como texto libre
+func redactarJSON(cuerpo []byte) string {
+	decodificador := json.NewDecoder(bytes.NewReader(cuerpo))
+	decodificador.UseNumber()
+	var datos interface{}
+	if err := decodificador.Decode(&datos); err != nil {
+		return redactarTexto(string(cuerpo))
+	}
+	redactado, err := json.Marshal(redactarTextos(redactarClaves(redactar(datos, ""))))
+	if err != nil {
+		return textoRedactado
+	}
+	return string(redactado)
+}
+
+// Reemplaza los datos personales reconocibles de un texto libre, como el
+// mensaje de un error
+func redactarTexto(texto string) string {
+	for _, patron := range patronesSensibles {
+		texto = patron.ReplaceAllString(texto, textoRedactado)
+	}
+	return texto
+}
+
+// Middleware que registra cada solicitud al terminar, con la ruta (sin ids
+// ni query), el estado y la latencia
+func registrarSolicitudes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		inicio := time.Now()
+		c.Next()
+
+		ruta := c.FullPath()
+		if ruta == "" {
+			ruta = "desconocida"
+		}
+		estado := c.Writer.Status()
+		nivel := slog.LevelInfo
+		switch {
+		case estado >= 500:
+			nivel = slog.LevelError
+		case estado >= 400:
+			nivel = slog.LevelWarn
+		}
+
+		atributos := []slog.Attr{
+			slog.String("metodo", c.Request.Method),
+			slog.String("ruta", ruta),
+			slog.Int("estado", estado),
+			slog.Int64("latenciaMs", time.Since(inicio).Milliseconds()),
+			slog.Int("tamanoRespuesta", max(c.Writer.Size(), 0)),
+		}
+		if len(c.Errors) > 0 {
+			atributos = append(atributos, slog.String("error", c.Errors.String()))
+		}
+		slog.LogAttrs(c.Request.Context(), nivel, "Solicitud atendida", atributos...)
+	}
+}
+
+// Recupera los panics de los handlers, los registra y responde 500
+func recuperarPanicos() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, err any) {
+		slog.ErrorContext(c.Request.Context(), "Pánico al atender la solicitud", "error", fmt.Sprint(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+	})
+}
+
+// Transporte HTTP que envia el id de la solicitud al proveedor y registra
+// cada llamada con los mismos campos que las solicitudes
+type transporteRegistrado struct {
+	base http.RoundTripper
+}
+
+func (t transporteRegistrado) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if id := idDeSolicitud(ctx); id != "" {
+		req = req.Clone(ctx)
+		req.Header.Set(cabeceraIdSolicitud, id)
+	}
+
+	inicio := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	atributos := []slog.Attr{
+		slog.String("metodo", req.Method),
+		slog.String("ruta", endpointProveedor(req.URL.Path)),
+		slog.Int64("latenciaMs", time.Since(inicio).Milliseconds()),
+	}
+	if err != nil {
+		atributos = append(atributos, slog.Any("error", err))
+		slog.LogAttrs(ctx, slog.LevelError, "Llamada al proveedor fallida", atributos...)
+		return nil, err
+	}
+
+	atributos = append(atributos, slog.Int("estado", resp.StatusCode))
+	nivel := slog.LevelInfo
+	if resp.StatusCode >= 400 {
+		nivel = slog.LevelWarn
+	}
+	slog.LogAttrs(ctx, nivel, "Llamada al proveedor", atributos...)
+	return resp, nil
+}
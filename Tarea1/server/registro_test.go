@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Registra con manejadorRegistro y devuelve la linea JSON escrita
+func registrar(t *testing.T, mensaje string, atributos ...any) string {
+	t.Helper()
+	var salida bytes.Buffer
+	registro := slog.New(manejadorRegistro{slog.NewJSONHandler(&salida, nil)})
+	registro.InfoContext(context.Background(), mensaje, atributos...)
+	return salida.String()
+}
+
+func TestRegistroRedactaDatosPersonales(t *testing.T) {
+	type pasajero struct {
+		Id   string `json:"id"`
+		Name struct {
+			FirstName string `json:"firstName"`
+		} `json:"name"`
+		Contacto string `json:"contacto"`
+	}
+	conDatos := pasajero{Id: "1", Contacto: "ana@pasajero.test"}
+	conDatos.Name.FirstName = "ANA"
+
+	casos := []struct {
+		nombre     string
+		mensaje    string
+		atributos  []any
+		conservado string
+	}{
+		{"claves en un grupo", "Reserva", []any{slog.Group("reserva", slog.String("id", "ABC123"), slog.String("nombre", "ANA PEREZ"), slog.Group("contacto", slog.String("email", "ana@pasajero.test")))}, "ABC123"},
+		{"error", "Error", []any{"error", errors.New("el proveedor rechazó a ana@pasajero.test con el pasaporte AB1234567")}, "el proveedor rechazó"},
+		{"estructura", "Pasajero", []any{"datos", conDatos}, `"id":"1"`},
+		{"mensaje", "Documento AB1234567 de ana@pasajero.test", nil, "Documento"},
+		{"vuelo", "Vuelo IB6830 del 2030-03-10", []any{"documento", "AB1234567"}, "IB6830"},
+	}
+	for _, caso := range casos {
+		linea := registrar(t, caso.mensaje, caso.atributos...)
+		for _, dato := range []string{"ANA", "ana@pasajero.test", "AB1234567"} {
+			if strings.Contains(linea, dato) {
+				t.Errorf("%s: el registro contiene %q: %s", caso.nombre, dato, linea)
+			}
+		}
+		if !strings.Contains(linea, caso.conservado) {
+			t.Errorf("%s: el registro perdió %q: %s", caso.nombre, caso.conservado, linea)
+		}
+	}
+}
+
+func TestRedactarTexto(t *testing.T) {
+	casos := []struct {
+		texto, esperado string
+	}{
+		{"correo ana@pasajero.test", "correo " + textoRedactado},
+		{"telefono +56 9 1234 5678", "telefono " + textoRedactado},
+		{"RUT 12.345.678-9 y 12345678-K", "RUT " + textoRedactado + " y " + textoRedactado},
+		{"pasaporte AB1234567", "pasaporte " + textoRedactado},
+		{"pasaporte P7654321", "pasaporte " + textoRedactado},
+		{"DNI 12345678Z", "DNI " + textoRedactado},
+		{"documento 123456789", "documento " + textoRedactado},
+		{"vuelo IB6830 asiento 12A a las 23:55", "vuelo IB6830 asiento 12A a las 23:55"},
+		{"orden eJzTd9f3NjIwNDAyNQAKJwIV", "orden eJzTd9f3NjIwNDAyNQAKJwIV"},
+	}
+	for _, caso := range casos {
+		if redactado := redactarTexto(caso.texto); redactado != caso.esperado {
+			t.Errorf("redactarTexto(%q) = %q, se esperaba %q", caso.texto, redactado, caso.esperado)
+		}
+	}
+}
+
+func TestRedactarJSONReservaAmadeus(t *testing.T) {
+	cuerpo, err := os.ReadFile(filepath.Join("testdata", "solicitudes", "booking.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	redactado := redactarJSON(cuerpo)
+	for _, dato := range []string{"ANA", "PEREZ", "1990-05-20", "FEMALE", "P7654321", "912345678", "ana@pasajero.test"} {
+		if strings.Contains(redactado, dato) {
+			t.Errorf("el cuerpo redactado contiene %q", dato)
+		}
+	}
+	// La oferta queda intacta y con la misma forma
+	for _, dato := range []string{`"carrierCode":"IB"`, `"number":"6830"`, `"total":"812.34"`, `"documents":[{`} {
+		if !strings.Contains(redactado, dato) {
+			t.Errorf("el cuerpo redactado perdió %s", dato)
+		}
+	}
+
+	if redactado := redactarJSON([]byte("no es JSON: ana@pasajero.test")); strings.Contains(redactado, "ana@pasajero.test") {
+		t.Errorf("texto no JSON = %q", redactado)
+	}
+}
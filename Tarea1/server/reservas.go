@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
+)
+
+const (
+	baseDeDatos       = "testgo"
+	coleccionReservas = "flightofferts"
+)
+
+var errReservaNoEncontrada = errors.New("reserva no encontrada")
+
+// Crea un cliente de MongoDB y comprueba la conexión. Quien lo llama debe
+// cerrar la conexión con Disconnect
+func conectarMongo(ctx context.Context) (*mongo.Client, error) {
+	// Establece la cadena de conexión de MongoDB
+	connectionString := config.MongoURI
+
+	// Configura las opciones de conexión
+	clientOptions := options.Client().ApplyURI(connectionString).SetMonitor(combinarMonitores(monitorMongo, otelmongo.NewMonitor()))
+
+	clientDB, err := mongo.Connect(ctx, clientOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := clientDB.Ping(ctx, nil); err != nil {
+		clientDB.Disconnect(ctx)
+		return nil, err
+	}
+	return clientDB, nil
+}
+
+// Busca una reserva guardada en la coleccion por su id de Amadeus. El id
+// puede llegar decodificado desde la URL, por lo que tambien se busca su
+// version codificada
+func buscarReservaGuardada(ctx context.Context, id string) (Booking, error) {
+	clientDB, err := conectarMongo(ctx)
+	if err != nil {
+		return Booking{}, err
+	}
+	defer clientDB.Disconnect(ctx)
+
+	collection := clientDB.Database(baseDeDatos).Collection(coleccionReservasDe(ctx))
+	filtro := bson.M{"data.id": bson.M{"$in": []string{id, url.QueryEscape(id)}}}
+
+	var reserva Booking
+	err = collection.FindOne(ctx, filtro).Decode(&reserva)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Booking{}, errReservaNoEncontrada
+	}
+	if err != nil {
+		return Booking{}, err
+	}
+	if err := descifrarReserva(&reserva); err != nil {
+		return Booking{}, err
+	}
+	return reserva, nil
+}
+
+// Marca como cancelada una reserva guardada
+func marcarReservaCancelada(ctx context.Context, id string) error {
+	clientDB, err := conectarMongo(ctx)
+	if err != nil {
+		return err
+	}
+	defer clientDB.Disconnect(ctx)
+
+	collection := clientDB.Database(baseDeDatos).Collection(coleccionReservasDe(ctx))
+	filtro := bson.M{"data.id": bson.M{"$in": []string{id, url.QueryEscape(id)}}}
+	actualizacion := bson.M{"$set": bson.M{"cancelada": true, "fechaCancelacion": time.Now().UTC()}}
+	_, err = collection.UpdateOne(ctx, filtro, actualizacion)
+	return err
+}
+
+// Tareas en segundo plano (por ejemplo los correos de confirmación) que se
+// esperan al apagar el servidor
+var tareas sync.WaitGroup
+
+func enSegundoPlano(tarea func()) {
+	tareas.Add(1)
+	go func() {
+		defer tareas.Done()
+		tarea()
+	}()
+}
+
+// Espera las tareas en segundo plano hasta que se cumpla el plazo del contexto
+func esperarTareas(ctx context.Context) {
+	terminadas := make(chan struct{})
+	go func() {
+		tareas.Wait()
+		close(terminadas)
+	}()
+
+	select {
+	case <-terminadas:
+	case <-ctx.Done():
+		slog.Warn("Se cumplió el plazo de apagado con tareas pendientes")
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Modos de retencion: anonimizar borra los datos personales de los
+// pasajeros y conserva la reserva; purge elimina la reserva completa
+const (
+	modoRetencionAnonimizar = "anonymize"
+	modoRetencionEliminar   = "purge"
+)
+
+// Cada cuanto se revisan las reservas vencidas
+const intervaloRetencion = 24 * time.Hour
+
+// Revisa las reservas vencidas al iniciar y luego cada intervaloRetencion,
+// hasta que se cancele ctx
+func iniciarRetencion(ctx context.Context) {
+	if config.Retencion <= 0 {
+		return
+	}
+
+	enSegundoPlano(func() {
+		ticker := time.NewTicker(intervaloRetencion)
+		defer ticker.Stop()
+		for {
+			if n, err := paraCadaAgencia(ctx, func(ctx context.Context) (int, error) { return aplicarRetencion(ctx, time.Now()) }); err != nil {
+				slog.ErrorContext(ctx, "Error al aplicar la retención de reservas", "error", err)
+			} else if n > 0 {
+				slog.InfoContext(ctx, "Retención de reservas aplicada", "cantidad", n, "modo", config.ModoRetencion)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+// Anonimiza o elimina las reservas cuyo ultimo vuelo salio hace mas de
+// config.Retencion. Devuelve la cantidad de reservas procesadas
+func aplicarRetencion(ctx context.Context, ahora time.Time) (int, error) {
+	clientDB, err := conectarMongo(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer clientDB.Disconnect(context.TODO())
+
+	// Las fechas de Amadeus (AAAA-MM-DDTHH:MM:SS) se pueden comparar como
+	// texto. Se buscan las reservas sin ningun segmento posterior al limite
+	limite := ahora.Add(-config.Retencion).Format(formatoAmadeus)
+	filtro := bson.M{
+		"data.flightoffers.itineraries.segments.departure.at": bson.M{"$exists": true, "$not": bson.M{"$gte": limite}},
+	}
+	if config.ModoRetencion == modoRetencionAnonimizar {
+		filtro["anonimizada"] = bson.M{"$ne": true}
+	}
+
+	collection := clientDB.Database(baseDeDatos).Collection(coleccionReservasDe(ctx))
+	cursor, err := collection.Find(ctx, filtro)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	procesadas := 0
+	for cursor.Next(ctx) {
+		var documento struct {
+			Id      interface{} `bson:"_id"`
+			Booking `bson:",inline"`
+		}
+		if err := cursor.Decode(&documento); err != nil {
+			return procesadas, err
+		}
+
+		if config.ModoRetencion == modoRetencionEliminar {
+			if _, err := collection.DeleteOne(ctx, bson.M{"_id": documento.Id}); err != nil {
+				return procesadas, err
+			}
+			auditarReserva(ctx, "ELIMINAR", documento.Data.Id, 0)
+			procesadas++
+			continue
+		}
+
+		// Se conservan los ids de los pasajeros para que la reserva siga
+		// siendo consistente con la oferta
+		anonimos, _ := transformarPasajeros(documento.Data.Travelers, func(string) (string, error) { return "", nil })
+		actualizacion := bson.M{"$set": bson.M{
+			"data.travelers":     anonimos,
+			"anonimizada":        true,
+			"fechaAnonimizacion": ahora.UTC(),
+		}}
+		if _, err := collection.UpdateOne(ctx, bson.M{"_id": documento.Id}, actualizacion); err != nil {
+			return procesadas, err
+		}
+		auditarReserva(ctx, "ANONIMIZAR", documento.Data.Id, 0)
+		procesadas++
+	}
+	return procesadas, cursor.Err()
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// El token se renueva un poco antes de que venza en Amadeus
+const margenToken = time.Minute
+
+// Credenciales de Amadeus de una agencia y el token obtenido con ellas. Las
+// credenciales pueden cambiar mientras el servidor corre cuando vienen de
+// archivos (secretos montados), por eso se protegen con un mutex
+type credencialesAgencia struct {
+	mu           sync.Mutex
+	clientID     string
+	clientSecret string
+	token        string
+	expira       time.Time
+
+	// Cambia con cada rotacion, para descartar tokens pedidos con las
+	// credenciales anteriores
+	version int
+
+	// Evita que varias solicitudes pidan un token a la vez
+	renovando sync.Mutex
+}
+
+// Lee un secreto desde un archivo, sin los espacios ni saltos de linea del
+// final
+func leerSecreto(archivo string) (string, error) {
+	contenido, err := os.ReadFile(archivo)
+	if err != nil {
+		return "", fmt.Errorf("no se pudo leer el secreto: %w", err)
+	}
+	secreto := strings.TrimSpace(string(contenido))
+	if secreto == "" {
+		return "", fmt.Errorf("el archivo %s está vacío", archivo)
+	}
+	return secreto, nil
+}
+
+// Credenciales configuradas para la agencia. Si hay archivo se usa su
+// contenido en vez del valor indicado directamente
+func (a *Agencia) leerCredenciales() (string, string, error) {
+	clientID, clientSecret := a.ClientID, a.ClientSecret
+	var err error
+	if a.ArchivoClientID != "" {
+		if clientID, err = leerSecreto(a.ArchivoClientID); err != nil {
+			return "", "", err
+		}
+	}
+	if a.ArchivoClientSecret != "" {
+		if clientSecret, err = leerSecreto(a.ArchivoClientSecret); err != nil {
+			return "", "", err
+		}
+	}
+	if clientID == "" || clientSecret == "" {
+		return "", "", errors.New("faltan las credenciales de Amadeus (CLIENT_ID y SECRECT_ID o CLIENT_ID_FILE y SECRECT_ID_FILE)")
+	}
+	return clientID, clientSecret, nil
+}
+
+// Carga las credenciales de la agencia. Si cambiaron se descarta el token
+// guardado y devuelve true
+func (a *Agencia) cargarCredenciales() (bool, error) {
+	clientID, clientSecret, err := a.leerCredenciales()
+	if err != nil {
+		return false, err
+	}
+
+	c := &a.credenciales
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if clientID == c.clientID && clientSecret == c.clientSecret {
+		return false, nil
+	}
+	c.clientID, c.clientSecret = clientID, clientSecret
+	c.token, c.expira = "", time.Time{}
+	c.version++
+	return true, nil
+}
+
+// Token guardado si aun no vence; si no, las credenciales con que pedir uno
+// nuevo y su version
+func (a *Agencia) tokenVigente(ahora time.Time) (token, clientID, clientSecret string, version int) {
+	c := &a.credenciales
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && ahora.Before(c.expira) {
+		return c.token, "", "", c.version
+	}
+	return "", c.clientID, c.clientSecret, c.version
+}
+
+// Guarda el token si las credenciales no rotaron mientras se pedia
+func (a *Agencia) guardarToken(version int, token string, expira time.Time) {
+	c := &a.credenciales
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if version == c.version {
+		c.token, c.expira = token, expira
+	}
+}
+
+// Revisa los archivos de credenciales cada config.IntervaloSecretos. Si un
+// secreto cambio se usa desde la siguiente solicitud, sin reiniciar el
+// servidor; si no se puede leer se siguen usando las credenciales anteriores
+func iniciarRecargaSecretos(ctx context.Context) {
+	if config.IntervaloSecretos <= 0 {
+		return
+	}
+
+	enSegundoPlano(func() {
+		ticker := time.NewTicker(config.IntervaloSecretos)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			for _, agencia := range listaAgencias() {
+				if agencia.ArchivoClientID == "" && agencia.ArchivoClientSecret == "" {
+					continue
+				}
+				cambio, err := agencia.cargarCredenciales()
+				if err != nil {
+					slog.ErrorContext(conAgencia(ctx, agencia), "No se pudieron recargar las credenciales de Amadeus", "error", err)
+				} else if cambio {
+					slog.InfoContext(conAgencia(ctx, agencia), "Credenciales de Amadeus rotadas")
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Escribe un archivo de secreto en el directorio temporal del test
+func escribirSecreto(t *testing.T, nombre, contenido string) string {
+	t.Helper()
+	archivo := filepath.Join(t.TempDir(), nombre)
+	if err := os.WriteFile(archivo, []byte(contenido), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return archivo
+}
+
+func TestLeerCredenciales(t *testing.T) {
+	agencia := &Agencia{
+		ClientID:            "id-directo",
+		ClientSecret:        "secreto-directo",
+		ArchivoClientID:     escribirSecreto(t, "client_id", "id-archivo\n"),
+		ArchivoClientSecret: escribirSecreto(t, "client_secret", "  secreto-archivo  \n"),
+	}
+	clientID, clientSecret, err := agencia.leerCredenciales()
+	if err != nil || clientID != "id-archivo" || clientSecret != "secreto-archivo" {
+		t.Errorf("con archivos = %q, %q, %v; los archivos tienen prioridad", clientID, clientSecret, err)
+	}
+
+	agencia.ArchivoClientSecret = ""
+	if _, clientSecret, err := agencia.leerCredenciales(); err != nil || clientSecret != "secreto-directo" {
+		t.Errorf("sin archivo del secreto = %q, %v", clientSecret, err)
+	}
+
+	agencia.ArchivoClientSecret = escribirSecreto(t, "vacio", " \n")
+	if _, _, err := agencia.leerCredenciales(); err == nil {
+		t.Error("se aceptó un archivo de secreto vacío")
+	}
+
+	agencia.ArchivoClientSecret = filepath.Join(t.TempDir(), "no_existe")
+	if _, _, err := agencia.leerCredenciales(); err == nil {
+		t.Error("se aceptó un archivo de secreto inexistente")
+	}
+
+	if _, _, err := (&Agencia{ClientID: "solo-id"}).leerCredenciales(); err == nil {
+		t.Error("se aceptaron credenciales sin secreto")
+	}
+}
+
+func TestRotarCredencialesDescartaElTokenAnterior(t *testing.T) {
+	archivo := escribirSecreto(t, "client_secret", "secreto-1")
+	agencia := &Agencia{ClientID: "id", ArchivoClientSecret: archivo}
+	if cambio, err := agencia.cargarCredenciales(); err != nil || !cambio {
+		t.Fatalf("primera carga = %v, %v", cambio, err)
+	}
+	if cambio, err := agencia.cargarCredenciales(); err != nil || cambio {
+		t.Errorf("sin cambios = %v, %v, no se esperaba rotación", cambio, err)
+	}
+
+	ahora := time.Now()
+	_, _, secreto, version := agencia.tokenVigente(ahora)
+	if secreto != "secreto-1" {
+		t.Fatalf("secreto = %q", secreto)
+	}
+
+	// Mientras se pide un token con el secreto viejo, el secreto rota
+	if err := os.WriteFile(archivo, []byte("secreto-2"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if cambio, err := agencia.cargarCredenciales(); err != nil || !cambio {
+		t.Fatalf("rotación = %v, %v", cambio, err)
+	}
+	agencia.guardarToken(version, "token-viejo", ahora.Add(time.Hour))
+	token, _, secreto, nueva := agencia.tokenVigente(ahora)
+	if token != "" || secreto != "secreto-2" {
+		t.Fatalf("tras rotar = token %q, secreto %q; se debía descartar el token viejo", token, secreto)
+	}
+
+	agencia.guardarToken(nueva, "token-nuevo", ahora.Add(time.Hour))
+	if token, _, _, _ := agencia.tokenVigente(ahora); token != "token-nuevo" {
+		t.Errorf("token con las credenciales nuevas = %q", token)
+	}
+	if token, _, _, _ := agencia.tokenVigente(ahora.Add(2 * time.Hour)); token != "" {
+		t.Errorf("token vencido = %q", token)
+	}
+}
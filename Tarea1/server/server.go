@@ -0,0 +1,976 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joho/godotenv"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"gopkg.in/resty.v1"
+)
+
+// Mailer usado para las confirmaciones de reserva
+var mailer Mailer
+
+// Cliente HTTP para las llamadas a Amadeus. Cada llamada queda auditada
+var clienteAmadeus = nuevoClienteAmadeus(http.DefaultTransport)
+
+type AccessTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// ESTRUCTURA PARA EL BOOKING? XD
+type AssociatedRecords struct {
+	Reference        string `json:"reference"`
+	CreationDate     string `json:"creationDate"`
+	OriginSystemCode string `json:"originSystemCode"`
+	FlightOfferId    string `json:"flightOfferId"`
+}
+
+type Documents struct {
+	Number           string `json:"number"`
+	IssuanceDate     string `json:"issuanceDate"`
+	ExpiryDate       string `json:"expiryDate"`
+	IssuanceCountry  string `json:"issuanceCountry"`
+	IssuanceLocation string `json:"issuanceLocation"`
+	Nationality      string `json:"nationality"`
+	BirthPlace       string `json:"birthPlace"`
+	DocumentType     string `json:"documentType"`
+	Holder           bool   `json:"holder"`
+}
+
+type Phones struct {
+	DeviceType         string `json:"deviceType"`
+	CountryCallingCode string `json:"countryCallingCode"`
+	Number             string `json:"number"`
+}
+
+type Travelers struct {
+	Id          string `json:"id"`
+	DateOfBirth string `json:"dateOfBirth"`
+	Gender      string `json:"gender"`
+	Name        struct {
+		FirstName string `json:"firstName"`
+		LastName  string `json:"lastName"`
+	} `json:"name"`
+	Documents []Documents `json:"documents"`
+	Contact   struct {
+		Purpose      string   `json:"purpose"`
+		Phones       []Phones `json:"phones"`
+		EmailAddress string   `json:"emailAddress"`
+	} `json:"contact"`
+}
+
+type Booking struct {
+	Data struct {
+		Type              string              `json:"type"`
+		Id                string              `json:"id"`
+		QueuingOfficeId   string              `json:"queuingOfficeId"`
+		AssociatedRecords []AssociatedRecords `json:"AssociatedRecords"`
+		FlightOffers      []FlightOffer       `json:"flightOffers"`
+		Travelers         []Travelers         `json:"travelers"`
+		Tickets           []Ticket            `json:"tickets,omitempty"`
+	} `json:"data"`
+}
+
+// Boleto emitido para un pasajero. Amadeus los agrega a la orden al emitirla
+type Ticket struct {
+	DocumentType   string   `json:"documentType"`
+	DocumentNumber string   `json:"documentNumber"`
+	DocumentStatus string   `json:"documentStatus"`
+	TravelerId     string   `json:"travelerId"`
+	SegmentIds     []string `json:"segmentIds"`
+}
+
+type Departure struct {
+	IataCode string `json:"iataCode"`
+	At       string `json:"at"`
+}
+
+type Arrival struct {
+	IataCode string `json:"iataCode"`
+	At       string `json:"at"`
+}
+
+type Aircraft struct {
+	Code string `json:"code"`
+}
+
+type Segment struct {
+	Departure       Departure `json:"departure"`
+	Arrival         Arrival   `json:"arrival"`
+	CarrierCode     string    `json:"carrierCode"`
+	Number          string    `json:"number"`
+	Aircraft        Aircraft  `json:"aircraft"`
+	Duration        string    `json:"duration"`
+	Id              string    `json:"id"`
+	NumberOfStops   int       `json:"numberOfStops"`
+	BlacklistedInEU bool      `json:"blacklistedInEU"`
+}
+
+type Itinerary struct {
+	Duration string    `json:"duration"`
+	Segments []Segment `json:"segments"`
+}
+
+type Fee struct {
+	Amount string `json:"amount"`
+	Type   string `json:"type"`
+}
+
+type Price struct {
+	Currency           string              `json:"currency"`
+	Total              string              `json:"total"`
+	Base               string              `json:"base"`
+	Fees               []Fee               `json:"fees"`
+	GrandTotal         string              `json:"grandTotal"`
+	AdditionalServices []AdditionalService `json:"additionalServices,omitempty"`
+}
+
+type PricingOptions struct {
+	FareType                []string `json:"fareType"`
+	IncludedCheckedBagsOnly bool     `json:"includedCheckedBagsOnly"`
+}
+
+type AdditionalService struct {
+	Amount string `json:"amount"`
+	Type   string `json:"type"`
+}
+
+// Equipaje adicional que el pasajero agrega a un segmento
+type ChargeableCheckedBags struct {
+	Quantity   int    `json:"quantity"`
+	Weight     int    `json:"weight,omitempty"`
+	WeightUnit string `json:"weightUnit,omitempty"`
+}
+
+type SegmentAdditionalServices struct {
+	ChargeableCheckedBags *ChargeableCheckedBags `json:"chargeableCheckedBags,omitempty"`
+	OtherServices         []string               `json:"otherServices,omitempty"`
+	ChargeableSeatNumber  string                 `json:"chargeableSeatNumber,omitempty"`
+}
+
+type FareDetailsBySegment struct {
+	SegmentId           string `json:"segmentId"`
+	Cabin               string `json:"cabin"`
+	FareBasis           string `json:"fareBasis"`
+	BrandedFare         string `json:"brandedFare"`
+	Class               string `json:"class"`
+	IncludedCheckedBags struct {
+		Quantity   int    `json:"quantity"`
+		Weight     int    `json:"weight,omitempty"`
+		WeightUnit string `json:"weightUnit,omitempty"`
+	} `json:"includedCheckedBags"`
+	AdditionalServices *SegmentAdditionalServices `json:"additionalServices,omitempty"`
+}
+
+type TravelerPricing struct {
+	TravelerId        string `json:"travelerId"`
+	FareOption        string `json:"fareOption"`
+	TravelerType      string `json:"travelerType"`
+	AssociatedAdultId string `json:"associatedAdultId,omitempty"`
+	Price             struct {
+		Currency   string `json:"currency"`
+		Total      string `json:"total"`
+		Base       string `json:"base"`
+		Fees       []Fee  `json:"fees"`
+		GrandTotal string `json:"grandTotal"`
+	} `json:"price"`
+	FareDetailsBySegment []FareDetailsBySegment `json:"fareDetailsBySegment"`
+	AdditionalServices   []AdditionalService    `json:"additionalServices"`
+}
+
+type FlightOffer struct {
+	Type                     string                `json:"type"`
+	Id                       string                `json:"id"`
+	Source                   string                `json:"source"`
+	InstantTicketingRequired bool                  `json:"instantTicketingRequired"`
+	NonHomogeneous           bool                  `json:"nonHomogeneous"`
+	OneWay                   bool                  `json:"oneWay"`
+	LastTicketingDate        string                `json:"lastTicketingDate"`
+	LastTicketingDateTime    string                `json:"lastTicketingDateTime,omitempty"`
+	NumberOfBookableSeats    int                   `json:"numberOfBookableSeats"`
+	Itineraries              []Itinerary           `json:"itineraries"`
+	Price                    Price                 `json:"price"`
+	PricingOptions           PricingOptions        `json:"pricingOptions"`
+	ValidatingAirlineCodes   []string              `json:"validatingAirlineCodes"`
+	TravelerPricings         []TravelerPricing     `json:"travelerPricings"`
+	Emisiones                *EmisionesCO2         `json:"co2Emissions,omitempty"`
+	Politica                 *CumplimientoPolitica `json:"policy,omitempty"`
+	Proveedor                string                `json:"provider,omitempty"`
+	IdProveedor              string                `json:"providerOfferId,omitempty"`
+}
+type Links struct {
+	Self string `json:"self"`
+}
+
+type Meta struct {
+	Count int   `json:"count"`
+	Links Links `json:"links"`
+}
+
+type FlightOffersResponse struct {
+	Meta Meta          `json:"meta"`
+	Data []FlightOffer `json:"data"`
+}
+
+type FlightOffersPricing struct {
+	Data struct {
+		Type         string        `json:"type"`
+		FlightOffers []FlightOffer `json:"flightOffers"`
+	} `json:"data"`
+	Included Included `json:"included"`
+}
+
+// Opciones de equipaje y servicios adicionales que entrega el pricing
+type ServicePrice struct {
+	Amount       string `json:"amount"`
+	CurrencyCode string `json:"currencyCode"`
+}
+
+type ServiceOption struct {
+	Quantity               int          `json:"quantity,omitempty"`
+	Weight                 int          `json:"weight,omitempty"`
+	WeightUnit             string       `json:"weightUnit,omitempty"`
+	Name                   string       `json:"name"`
+	Price                  ServicePrice `json:"price"`
+	BookableByAllTravelers bool         `json:"bookableByAllTravelers"`
+	TravelerIds            []string     `json:"travelerIds"`
+	SegmentIds             []string     `json:"segmentIds"`
+}
+
+type Included struct {
+	Bags          map[string]ServiceOption `json:"bags,omitempty"`
+	OtherServices map[string]ServiceOption `json:"other-services,omitempty"`
+}
+
+// Respuesta de /pricing hacia el cliente
+type PricingResponse struct {
+	FlightOffers []FlightOffer     `json:"flightOffers"`
+	Included     Included          `json:"included"`
+	Condiciones  []CondicionTarifa `json:"conditions,omitempty"`
+}
+
+func obtenerToken(ctx context.Context) (string, error) {
+	// Cada agencia usa sus propias credenciales
+	agencia := agenciaDe(ctx)
+	if agencia == nil {
+		return "", fmt.Errorf("la solicitud no indica la agencia")
+	}
+
+	// Se reutiliza el token mientras no venza. Si otra solicitud lo esta
+	// renovando se espera a que termine y se usa el suyo
+	if token, _, _, _ := agencia.tokenVigente(time.Now()); token != "" {
+		return token, nil
+	}
+	agencia.credenciales.renovando.Lock()
+	defer agencia.credenciales.renovando.Unlock()
+	token, clientID, clientSecret, version := agencia.tokenVigente(time.Now())
+	if token != "" {
+		return token, nil
+	}
+
+	// Configura la URL de la API de Amadeus para obtener el token
+	apiUrl := config.URLProveedor + "/v1/security/oauth2/token"
+
+	// Configura los datos del formulario para la solicitud POST
+	data := map[string]string{
+		"grant_type":    "client_credentials",
+		"client_id":     clientID,
+		"client_secret": clientSecret,
+	}
+
+	// Crea una instancia de Resty
+	client := resty.New().SetTransport(clienteAmadeus.Transport)
+
+	// Realiza la solicitud POST para obtener el token
+	solicitado := time.Now()
+	resp, err := client.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/x-www-form-urlencoded").
+		SetFormData(data).
+		Post(apiUrl)
+
+	if err != nil {
+		renovacionesToken.WithLabelValues("error").Inc()
+		return "", err
+	}
+	if resp.IsError() {
+		renovacionesToken.WithLabelValues("error").Inc()
+		return "", fmt.Errorf("Amadeus no entregó el token: %s", resp.Status())
+	}
+
+	// Deserializa la respuesta JSON y obtén el token de acceso
+	var tokenResponse AccessTokenResponse
+	if err := json.Unmarshal(resp.Body(), &tokenResponse); err != nil {
+		renovacionesToken.WithLabelValues("error").Inc()
+		return "", err
+	}
+
+	// Sin expires_in el token se usa solo para esta solicitud
+	if tokenResponse.ExpiresIn > 0 {
+		vigencia := time.Duration(tokenResponse.ExpiresIn)*time.Second - margenToken
+		agencia.guardarToken(version, tokenResponse.AccessToken, solicitado.Add(vigencia))
+	}
+
+	renovacionesToken.WithLabelValues("ok").Inc()
+	return tokenResponse.AccessToken, nil
+}
+
+func buscarVuelos(c *gin.Context) {
+
+	parametros := ParametrosBusqueda{
+		Origen:       c.Query("originLocationCode"),
+		Destino:      c.Query("destinationLocationCode"),
+		FechaSalida:  c.Query("departureDate"),
+		FechaRegreso: c.Query("returnDate"),
+		Adultos:      c.Query("adults"),
+		Ninos:        c.Query("children"),
+		Infantes:     c.Query("infants"),
+		Mayores:      c.Query("seniors"),
+	}
+	if err := parametros.validar(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	orden := c.Query("sort")
+	if err := validarOrden(orden); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resultado, err := buscarEnProveedores(c.Request.Context(), parametros, nil)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error al buscar vuelos", "error", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Error al buscar vuelos", "warnings": resultado.Advertencias})
+		return
+	}
+
+	ordenarOfertas(resultado.Ofertas, orden)
+	c.JSON(http.StatusOK, resultado)
+}
+
+func obtenerPreciosAmadeus(c *gin.Context) {
+
+	token, err := obtenerToken(c.Request.Context())
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error al obtener el token", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener el token"})
+		return
+	}
+
+	// Se solicitan las opciones de equipaje, los servicios adicionales y las
+	// reglas de la tarifa junto al precio
+	apiUrl := config.URLProveedor + "/v1/shopping/flight-offers/pricing?include=bags,other-services,detailed-fare-rules"
+
+	// Leer los datos JSON del cuerpo de la solicitud
+	var datosJSON map[string]interface{}
+	if err := c.ShouldBindJSON(&datosJSON); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Convertir los datos JSON en bytes
+	if err := quitarAnotacionesJSON(datosJSON); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	datosBytes, err := json.Marshal(datosJSON)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Una oferta vencida no se puede cotizar ni reservar
+	var solicitud FlightOffersPricing
+	if err := json.Unmarshal(datosBytes, &solicitud); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validarPlazoEmision(solicitud.Data.FlightOffers, time.Now()); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Crear una solicitud HTTP POST
+	req, err := http.NewRequestWithContext(c.Request.Context(), "POST", apiUrl, bytes.NewBuffer(datosBytes))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	// Enviar la solicitud
+	resp, err := clienteAmadeus.Do(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error al leer la respuesta", "error", err)
+		return
+	}
+
+	var response FlightOffersPricing
+	//Deserializar respuesta
+	if err := json.Unmarshal(body, &response); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error al deserializar el JSON", "error", err)
+		return
+	}
+
+	// El precio cotizado puede cambiar el cumplimiento de la politica
+	anotarOfertas(c.Request.Context(), response.Data.FlightOffers)
+
+	// Devolver la respuesta al cliente
+	c.JSON(http.StatusOK, PricingResponse{
+		FlightOffers: response.Data.FlightOffers,
+		Included:     response.Included,
+		Condiciones:  normalizarCondiciones(body, response.Data.FlightOffers),
+	})
+}
+
+func hacerreserva(c *gin.Context) {
+	// Resultado de la reserva para las métricas
+	resultado := "error"
+	defer func() { reservasRealizadas.WithLabelValues(resultado).Inc() }()
+
+	// La reserva continúa aunque el cliente se desconecte, para que un
+	// reintento con la misma Idempotency-Key reciba su resultado
+	ctx := context.WithoutCancel(c.Request.Context())
+
+	// Leer los datos JSON del cuerpo de la solicitud
+	var datosJSON map[string]interface{}
+	if err := c.ShouldBindJSON(&datosJSON); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// El motivo de aprobacion es solo para el servidor, no para el proveedor
+	motivo, _ := datosJSON["approvalReason"].(string)
+	delete(datosJSON, "approvalReason")
+
+	// Convertir los datos JSON en bytes
+	if err := quitarAnotacionesJSON(datosJSON); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	datosBytes, err := json.Marshal(datosJSON)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Validar los pasajeros antes de enviar la reserva al proveedor
+	var solicitud Booking
+	if err := json.Unmarshal(datosBytes, &solicitud); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errores := validarPasajeros(solicitud); len(errores) > 0 {
+		resultado = "rechazada"
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Pasajeros no válidos", "detalles": errores})
+		return
+	}
+	if err := validarPlazoEmision(solicitud.Data.FlightOffers, time.Now()); err != nil {
+		resultado = "rechazada"
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	// Una oferta fuera de la politica de viajes solo se reserva con un motivo
+	var aprobacion *AprobacionPolitica
+	if infracciones := infraccionesPolitica(ctx, solicitud.Data.FlightOffers); len(infracciones) > 0 {
+		if strings.TrimSpace(motivo) == "" {
+			resultado = "rechazada"
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "La oferta no cumple la política de viajes, indique approvalReason", "violations": infracciones})
+			return
+		}
+		aprobacion = &AprobacionPolitica{Infracciones: infracciones, Motivo: motivo}
+	}
+
+	// Las ofertas fuera de la politica quedan pendientes hasta que un
+	// aprobador las acepte
+	if aprobacion != nil {
+		pendiente, err := crearSolicitudAprobacion(ctx, datosBytes, solicitud, *aprobacion, time.Now())
+		if errors.Is(err, errAprobacionSinCifrado) {
+			slog.ErrorContext(ctx, "No se puede guardar la solicitud de aprobación sin PII_KEYS")
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Error al guardar la solicitud de aprobación", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al guardar la solicitud de aprobación"})
+			return
+		}
+		resultado = "pendiente"
+		c.JSON(http.StatusAccepted, pendiente)
+		return
+	}
+
+	orden, err := crearOrden(ctx, datosBytes, nil)
+	if errors.Is(err, errOrdenIncierta) {
+		// La clave queda en proceso: un reintento podria duplicar la reserva
+		conservarClaveIdempotencia(c)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "No se sabe si el proveedor creó la reserva, revise sus reservas antes de reintentar"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// El proveedor no creo la reserva
+	response := orden.Reserva
+	if response.Data.Id == "" && orden.Advertencia == "" {
+		resultado = "rechazada"
+		c.JSON(estadoRechazoProveedor(orden.Estado), gin.H{"error": mensajeRechazoProveedor(orden.Cuerpo)})
+		return
+	}
+
+	// Notificar a los pasajeros sin bloquear la respuesta
+	if response.Data.Id != "" {
+		resultado = "exito"
+		enSegundoPlano(func() { enviarConfirmaciones(mailer, response) })
+	}
+
+	// Devolver la respuesta al cliente
+	respuesta := gin.H{"id": response.Data.Id}
+	if orden.Advertencia != "" {
+		respuesta["warning"] = orden.Advertencia
+	}
+	c.JSON(http.StatusOK, respuesta)
+
+}
+
+// Tipos de cambio para las reservas creadas que no se pudieron guardar
+// completas
+const (
+	cambioRespuestaIlegible = "RESPUESTA_ILEGIBLE"
+	cambioSinCifrar         = "PASAJEROS_SIN_CIFRAR"
+)
+
+// El proveedor no respondio, o respondio con un error 5xx, y pudo haber
+// creado la reserva. No se debe reintentar sin revisar antes las reservas
+// del proveedor
+var errOrdenIncierta = errors.New("no se sabe si el proveedor creó la reserva")
+
+// Resultado de enviar una reserva al proveedor. Estado y Cuerpo son la
+// respuesta del proveedor; Advertencia explica por que una reserva creada
+// quedo marcada para revision
+type ResultadoOrden struct {
+	Reserva     Booking
+	Estado      int
+	Cuerpo      []byte
+	Advertencia string
+}
+
+// Envia la reserva al proveedor y guarda la respuesta en MongoDB, junto a
+// la aprobacion si la oferta estaba fuera de la politica. Cuando el
+// proveedor responde 2xx la reserva ya existe, por eso desde ese punto los
+// problemas se informan en Advertencia y no como error
+func crearOrden(ctx context.Context, datosBytes []byte, aprobacion *AprobacionPolitica) (ResultadoOrden, error) {
+	token, err := obtenerToken(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error al obtener el token", "error", err)
+		return ResultadoOrden{}, errors.New("Error al obtener el token")
+	}
+
+	// La base se abre antes de reservar para no crear una orden que no se
+	// pueda guardar
+	clientDB, err := conectarMongo(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error al conectar con MongoDB", "error", err)
+		return ResultadoOrden{}, errors.New("Error al conectar con la base de datos")
+	}
+	defer clientDB.Disconnect(context.TODO())
+	collection := clientDB.Database(baseDeDatos).Collection(coleccionReservasDe(ctx))
+
+	apiUrl := config.URLProveedor + "/v1/booking/flight-orders"
+
+	// Crear una solicitud HTTP POST
+	req, err := http.NewRequestWithContext(ctx, "POST", apiUrl, bytes.NewBuffer(datosBytes))
+	if err != nil {
+		return ResultadoOrden{}, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	// Enviar la solicitud
+	resp, err := clienteAmadeus.Do(req)
+	if err != nil {
+		if solicitudNoEnviada(err) {
+			return ResultadoOrden{}, err
+		}
+		slog.ErrorContext(ctx, "El proveedor no respondió a la reserva", "error", err)
+		auditarReserva(ctx, "CREAR_INCIERTA", "", 0)
+		return ResultadoOrden{}, fmt.Errorf("%w: %v", errOrdenIncierta, err)
+	}
+	defer resp.Body.Close()
+
+	body, errLectura := io.ReadAll(resp.Body)
+	resultado := ResultadoOrden{Estado: resp.StatusCode, Cuerpo: body}
+	if resp.StatusCode >= 500 {
+		// Un error del proveedor (por ejemplo un 504 de su pasarela) no
+		// asegura que la orden no se haya creado
+		slog.ErrorContext(ctx, "El proveedor respondió con un error a la reserva", "estado", resp.StatusCode)
+		auditarReserva(ctx, "CREAR_INCIERTA", "", resp.StatusCode)
+		return resultado, fmt.Errorf("%w: el proveedor respondió %d", errOrdenIncierta, resp.StatusCode)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		// El proveedor rechazo la reserva, no hay nada que guardar
+		auditarReserva(ctx, "CREAR", "", resp.StatusCode)
+		return resultado, nil
+	}
+
+	//Deserializar respuesta
+	detalle := ""
+	if errLectura != nil {
+		detalle = "No se pudo leer la respuesta del proveedor: " + errLectura.Error()
+	} else if err := json.Unmarshal(body, &resultado.Reserva); err != nil {
+		detalle = "No se pudo deserializar la respuesta del proveedor: " + err.Error()
+	} else if resultado.Reserva.Data.Id == "" {
+		detalle = "La respuesta del proveedor no trae el id de la reserva"
+	}
+	if detalle != "" {
+		slog.ErrorContext(ctx, "Reserva creada con una respuesta ilegible, queda para revisión", "estado", resp.StatusCode, "detalle", detalle)
+		resultado.Advertencia = "La reserva se creó en el proveedor, pero su respuesta no se pudo leer; quedó marcada para revisión"
+		guardada := ReservaGuardada{
+			Aprobacion:       aprobacion,
+			Crudo:            respuestaParaGuardar(body),
+			RequiereRevision: true,
+			Historial:        []CambioReserva{{Fecha: time.Now().UTC(), Tipo: cambioRespuestaIlegible, Detalle: detalle}},
+		}
+		reservasParaRevision.WithLabelValues("respuesta_ilegible").Inc()
+		if _, err := collection.InsertOne(ctx, guardada); err != nil {
+			slog.ErrorContext(ctx, "Error al guardar la respuesta ilegible del proveedor", "error", err)
+			reservasParaRevision.WithLabelValues("sin_guardar").Inc()
+		}
+		auditarReserva(ctx, "CREAR", resultado.Reserva.Data.Id, resp.StatusCode)
+		return resultado, nil
+	}
+
+	// Los datos personales de los pasajeros se guardan cifrados. Si no se
+	// pueden cifrar la reserva se guarda sin ellos y queda para revision
+	id := resultado.Reserva.Data.Id
+	guardada := ReservaGuardada{Aprobacion: aprobacion}
+	if cifrada, err := cifrarReserva(resultado.Reserva); err == nil {
+		guardada.Booking = cifrada
+	} else {
+		slog.ErrorContext(ctx, "Error al cifrar la reserva, se guarda sin los datos de los pasajeros", "idReserva", id, "error", err)
+		reservasParaRevision.WithLabelValues("sin_cifrar").Inc()
+		guardada.Booking = resultado.Reserva
+		guardada.Data.Travelers, _ = transformarPasajeros(resultado.Reserva.Data.Travelers, func(string) (string, error) { return "", nil })
+		guardada.RequiereRevision = true
+		guardada.Historial = []CambioReserva{{Fecha: time.Now().UTC(), Tipo: cambioSinCifrar, Detalle: "No se pudieron cifrar los datos de los pasajeros: " + err.Error()}}
+		resultado.Advertencia = "La reserva se creó, pero los datos de los pasajeros no se pudieron guardar; quedó marcada para revisión"
+	}
+	if _, err := collection.InsertOne(ctx, guardada); err != nil {
+		slog.ErrorContext(ctx, "Error al guardar la reserva creada en el proveedor", "idReserva", id, "error", err)
+		reservasParaRevision.WithLabelValues("sin_guardar").Inc()
+		resultado.Advertencia = "La reserva se creó en el proveedor, pero no se pudo guardar; contacte a la agencia con el id de la reserva"
+	}
+	auditarReserva(ctx, "CREAR", id, resp.StatusCode)
+	return resultado, nil
+}
+
+// Estado con el que se informa al cliente una reserva que el proveedor no
+// creo. Los errores de la solicitud (4xx) se devuelven tal cual; los de
+// autenticacion y los del proveedor son problemas del servidor y se
+// informan como 502
+func estadoRechazoProveedor(estado int) int {
+	if estado >= 400 && estado < 500 && estado != http.StatusUnauthorized && estado != http.StatusForbidden {
+		return estado
+	}
+	return http.StatusBadGateway
+}
+
+// Mensaje de error de una respuesta de Amadeus ({"errors": [{"title",
+// "detail"}]}), o uno generico si no lo trae
+func mensajeRechazoProveedor(cuerpo []byte) string {
+	var respuesta struct {
+		Errors []struct {
+			Title  string `json:"title"`
+			Detail string `json:"detail"`
+		} `json:"errors"`
+	}
+	if json.Unmarshal(cuerpo, &respuesta) == nil {
+		for _, e := range respuesta.Errors {
+			switch {
+			case e.Title != "" && e.Detail != "":
+				return "El proveedor rechazó la reserva: " + e.Title + " (" + e.Detail + ")"
+			case e.Title != "" || e.Detail != "":
+				return "El proveedor rechazó la reserva: " + e.Title + e.Detail
+			}
+		}
+	}
+	return "El proveedor rechazó la reserva"
+}
+
+// Indica si el error ocurrio antes de que la solicitud llegara al
+// proveedor, por ejemplo al no poder conectarse
+func solicitudNoEnviada(err error) bool {
+	var errRed *net.OpError
+	return errors.As(err, &errRed) && errRed.Op == "dial"
+}
+
+// Prepara una respuesta del proveedor para guardarla: cifrada si hay
+// claves PII_KEYS y, si no, sin los datos personales reconocibles
+func respuestaParaGuardar(cuerpo []byte) string {
+	if clavesPII != nil {
+		if cifrado, err := clavesPII.cifrar(string(cuerpo)); err == nil {
+			return cifrado
+		}
+	}
+	return redactarJSON(cuerpo)
+}
+
+func buscarId(c *gin.Context) {
+	token, err := obtenerToken(c.Request.Context())
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error al obtener el token", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener el token"})
+		return
+	}
+
+	//Leer parametros
+	id_aux := c.Query("id")
+	apiUrl := config.URLProveedor + "/v1/booking/flight-orders/" + id_aux
+
+	// Crear una solicitud HTTP GET
+	req, err := http.NewRequestWithContext(c.Request.Context(), "GET", apiUrl, nil)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error al crear la solicitud", "error", err)
+		return
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	// Enviar la solicitud
+	resp, err := clienteAmadeus.Do(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error al leer la respuesta", "error", err)
+		return
+	}
+
+	var response Booking
+	//Deserializar respuesta
+	if err := json.Unmarshal(body, &response); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error al deserializar el JSON", "error", err)
+		return
+	}
+
+	// Devolver la respuesta al cliente
+	c.JSON(http.StatusOK, response.Data)
+
+}
+
+func cancelarReserva(c *gin.Context) {
+	token, err := obtenerToken(c.Request.Context())
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error al obtener el token", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener el token"})
+		return
+	}
+
+	id := c.Param("id")
+	apiUrl := config.URLProveedor + "/v1/booking/flight-orders/" + url.PathEscape(id)
+
+	// Crear una solicitud HTTP DELETE
+	req, err := http.NewRequestWithContext(c.Request.Context(), "DELETE", apiUrl, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := clienteAmadeus.Do(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	auditarReserva(c.Request.Context(), "CANCELAR", id, resp.StatusCode)
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		c.JSON(resp.StatusCode, gin.H{"error": "El proveedor no pudo cancelar la reserva"})
+		return
+	}
+
+	// La reserva ya se canceló en el proveedor: se marca aunque el cliente
+	// se desconecte, en la colección de su agencia
+	if err := marcarReservaCancelada(context.WithoutCancel(c.Request.Context()), id); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error al actualizar la reserva cancelada", "error", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": id, "cancelada": true})
+}
+
+// Crea el router con todas las rutas del servidor. Se separa de main para
+// poder probar los handlers con httptest
+func nuevoRouter() *gin.Engine {
+	r := gin.New()
+	// Las metricas y el registro van antes de la recuperacion para contar
+	// tambien las solicitudes que terminan en panic
+	r.Use(otelgin.Middleware(nombreServicio), idSolicitud(), registrarSolicitudes(), medirSolicitudes(), recuperarPanicos())
+
+	r.GET("/metrics", exponerMetricas())
+
+	// Las rutas de los clientes usan la agencia de su clave
+	api := r.Group("", identificarAgencia())
+	api.GET("/search", buscarVuelos)
+	api.GET("/search/calendar", buscarFechasFlexibles)
+	api.GET("/search/stream", buscarVuelosEnVivo)
+	api.GET("/search/calendar/stream", buscarFechasFlexiblesEnVivo)
+	api.POST("/pricing", obtenerPreciosAmadeus)
+	api.POST("/seatmaps", mapaAsientosOferta)
+	api.POST("/booking", idempotente(), hacerreserva)
+	api.GET("/booking", buscarId)
+	api.DELETE("/booking/:id", cancelarReserva)
+	api.GET("/booking/:id/seatmaps", mapaAsientosReserva)
+	api.GET("/approvals/:id", consultarAprobacion)
+	api.GET("/booking/:id/calendar.ics", exportarCalendario)
+	api.GET("/booking/:id/itinerary.html", exportarItinerarioHTML)
+	api.GET("/booking/:id/itinerary.pdf", exportarItinerarioPDF)
+
+	admin := r.Group("/admin", soloAdministradores(), agenciaAdministrada())
+	admin.GET("/audit", consultarAuditoria)
+	admin.POST("/pii/rotate", rotarClavesPII)
+	admin.GET("/bookings/flagged", consultarReservasMarcadas)
+	admin.POST("/bookings/:id/reviewed", marcarReservaRevisada)
+	admin.GET("/approvals", consultarAprobaciones)
+	admin.POST("/approvals/:id/approve", aprobarReserva)
+	admin.POST("/approvals/:id/reject", rechazarReserva)
+
+	return r
+}
+
+func main() {
+
+	// Los registros son JSON desde el inicio; el nivel se ajusta al leer la
+	// configuracion
+	configurarRegistro("")
+
+	// El archivo .env es opcional: la configuración también puede venir del
+	// entorno, de un archivo JSON o de flags
+	if err := godotenv.Load(); err != nil {
+		slog.Info("No se cargó el archivo .env", "error", err)
+	}
+
+	cfg, err := cargarConfiguracion(os.Args[1:])
+	if err != nil {
+		slog.Error("Error en la configuración", "error", err)
+		os.Exit(1)
+	}
+	config = cfg
+
+	if err := configurarRegistro(config.NivelRegistro); err != nil {
+		slog.Error("Error en la configuración", "error", err)
+		os.Exit(1)
+	}
+
+	apagarTrazas, err := iniciarTrazas(context.Background())
+	if err != nil {
+		slog.Error("Error al iniciar las trazas", "error", err)
+		os.Exit(1)
+	}
+
+	clavesPII, err = cargarClavesPII(config.ClavesPII)
+	if err != nil {
+		slog.Error("Error en la configuración", "error", err)
+		os.Exit(1)
+	}
+	if clavesPII == nil {
+		slog.Warn("PII_KEYS no está configurada: los datos de los pasajeros se guardarán sin cifrar")
+	}
+
+	agencias, agenciaPorDefecto, err = cargarAgencias(config.ArchivoAgencias, config)
+	if err != nil {
+		slog.Error("Error en la configuración", "error", err)
+		os.Exit(1)
+	}
+
+	mailer = crearMailer(config)
+
+	iniciarAuditoria()
+
+	if err := usarModoProveedor(config.ModoProveedor, config.DirectorioFixtures); err != nil {
+		slog.Error("Error en la configuración", "error", err)
+		os.Exit(1)
+	}
+
+	r := nuevoRouter()
+
+	srv := &http.Server{
+		Addr:    config.Direccion(),
+		Handler: r,
+	}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			slog.Error("Error al iniciar el servidor", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	// Esperar SIGINT o SIGTERM para apagar el servidor
+	senal, detener := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer detener()
+
+	// Las tareas periódicas se detienen junto con el servidor
+	iniciarRetencion(senal)
+	iniciarSincronizacion(senal)
+	iniciarAvisosEmision(senal)
+	iniciarVencimientoAprobaciones(senal)
+	iniciarRecargaSecretos(senal)
+
+	<-senal.Done()
+
+	slog.Info("Apagando el servidor, esperando las solicitudes en curso")
+	ctx, cancelar := context.WithTimeout(context.Background(), config.TiempoApagado)
+	defer cancelar()
+
+	// Shutdown deja de aceptar conexiones y espera a que terminen las
+	// solicitudes en curso, como las reservas que se están creando
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Error("Error al apagar el servidor", "error", err)
+	}
+
+	// Luego se esperan los correos pendientes y se vacía la auditoría
+	esperarTareas(ctx)
+	detenerAuditoria(ctx)
+
+	if err := apagarTrazas(ctx); err != nil {
+		slog.Error("Error al cerrar las trazas", "error", err)
+	}
+	slog.Info("Servidor detenido")
+}
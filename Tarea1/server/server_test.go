@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Fixtures de Amadeus para los tests de handlers. Se grabaron con
+// PROVIDER_MODE=record; para volver a grabarlas se ejecutan los tests con
+// GRABAR_AMADEUS=<url del proveedor> y las credenciales en CLIENT_ID y
+// SECRECT_ID
+const directorioFixturesPrueba = "testdata/amadeus"
+
+// Id de la orden que devuelven las fixtures de reserva
+const ordenFixture = "eJzTd9f3NjIwNDAyNQAKJwIV"
+
+// Prepara el servidor para probar los handlers sin red: configuracion por
+// defecto, la agencia por defecto y el proveedor reproducido desde las
+// fixtures. Devuelve el router de nuevoRouter
+func prepararReproduccion(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	anteriores := struct {
+		config   Configuracion
+		cliente  *http.Client
+		agencias map[string]*Agencia
+		defecto  *Agencia
+		mailer   Mailer
+		claves   *ClavesPII
+	}{config, clienteAmadeus, agencias, agenciaPorDefecto, mailer, clavesPII}
+	t.Cleanup(func() {
+		ctx, cancelar := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelar()
+		esperarTareas(ctx)
+		config, clienteAmadeus, agencias, agenciaPorDefecto = anteriores.config, anteriores.cliente, anteriores.agencias, anteriores.defecto
+		mailer, clavesPII = anteriores.mailer, anteriores.claves
+	})
+
+	config = configuracionPorDefecto()
+	config.URLProveedor = "https://test.api.amadeus.com"
+	config.ClientID, config.ClientSecret = "cliente", "secreto"
+	modo := modoProveedorReproducir
+	if url := os.Getenv("GRABAR_AMADEUS"); url != "" {
+		modo = modoProveedorGrabar
+		config.URLProveedor = url
+		config.ClientID, config.ClientSecret = os.Getenv("CLIENT_ID"), os.Getenv("SECRECT_ID")
+	}
+
+	var err error
+	agencias, agenciaPorDefecto, err = cargarAgencias("", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sin ruta los correos de confirmacion solo se registran
+	mailer = &MailerArchivo{}
+	if err := usarModoProveedor(modo, directorioFixturesPrueba); err != nil {
+		t.Fatal(err)
+	}
+	return nuevoRouter()
+}
+
+// Envia una solicitud al router y devuelve la respuesta grabada
+func solicitar(r http.Handler, metodo, ruta, archivoCuerpo string) *httptest.ResponseRecorder {
+	var cuerpo *strings.Reader
+	if archivoCuerpo != "" {
+		contenido, err := os.ReadFile(filepath.Join("testdata", "solicitudes", archivoCuerpo))
+		if err != nil {
+			panic(err)
+		}
+		cuerpo = strings.NewReader(string(contenido))
+	} else {
+		cuerpo = strings.NewReader("")
+	}
+	req := httptest.NewRequest(metodo, ruta, cuerpo)
+	if archivoCuerpo != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestSolicitudNoEnviadaDistingueConexionYTiempo(t *testing.T) {
+	// Un puerto sin servidor: la solicitud nunca llega al proveedor
+	escucha, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cerrado := escucha.Addr().String()
+	escucha.Close()
+	if _, err := http.Get("http://" + cerrado); err == nil || !solicitudNoEnviada(err) {
+		t.Errorf("conexión rechazada: solicitudNoEnviada(%v) = false", err)
+	}
+
+	// Un proveedor que recibe la reserva y no responde a tiempo
+	lento := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer lento.Close()
+	cliente := &http.Client{Timeout: 50 * time.Millisecond}
+	if _, err := cliente.Post(lento.URL, "application/json", strings.NewReader("{}")); err == nil || solicitudNoEnviada(err) {
+		t.Errorf("tiempo agotado: solicitudNoEnviada(%v) = true", err)
+	}
+}
+
+func TestRespuestaParaGuardarSinClavesRedacta(t *testing.T) {
+	anteriores := clavesPII
+	clavesPII = nil
+	defer func() { clavesPII = anteriores }()
+
+	cuerpo := `{"data":{"id":"ABC","travelers":[{"name":{"firstName":"ANA","lastName":"PEREZ"},"contact":{"emailAddress":"ana@pasajero.test"}}]}}`
+	guardado := respuestaParaGuardar([]byte(cuerpo))
+	for _, dato := range []string{"ANA", "PEREZ", "ana@pasajero.test"} {
+		if strings.Contains(guardado, dato) {
+			t.Errorf("la respuesta guardada contiene %q: %s", dato, guardado)
+		}
+	}
+	if !strings.Contains(guardado, `"ABC"`) {
+		t.Errorf("la respuesta guardada perdió el id de la reserva: %s", guardado)
+	}
+
+	if guardado := respuestaParaGuardar([]byte("no es json ana@pasajero.test")); strings.Contains(guardado, "ana@pasajero.test") {
+		t.Errorf("la respuesta no JSON guardada contiene el correo: %s", guardado)
+	}
+}
+
+func TestRechazoProveedor(t *testing.T) {
+	casos := []struct {
+		estado, esperado int
+	}{
+		{http.StatusBadRequest, http.StatusBadRequest},
+		{http.StatusUnprocessableEntity, http.StatusUnprocessableEntity},
+		{http.StatusUnauthorized, http.StatusBadGateway},
+		{http.StatusForbidden, http.StatusBadGateway},
+		{http.StatusInternalServerError, http.StatusBadGateway},
+		{0, http.StatusBadGateway},
+	}
+	for _, caso := range casos {
+		if estado := estadoRechazoProveedor(caso.estado); estado != caso.esperado {
+			t.Errorf("estadoRechazoProveedor(%d) = %d, se esperaba %d", caso.estado, estado, caso.esperado)
+		}
+	}
+
+	cuerpo := `{"errors":[{"status":400,"code":34651,"title":"SEGMENT SELL FAILURE","detail":"Could not sell segment 1"}]}`
+	if mensaje := mensajeRechazoProveedor([]byte(cuerpo)); mensaje != "El proveedor rechazó la reserva: SEGMENT SELL FAILURE (Could not sell segment 1)" {
+		t.Errorf("mensajeRechazoProveedor = %q", mensaje)
+	}
+	if mensaje := mensajeRechazoProveedor([]byte("<html>")); mensaje != "El proveedor rechazó la reserva" {
+		t.Errorf("mensajeRechazoProveedor sin JSON = %q", mensaje)
+	}
+}
+
+func TestBuscarVuelosReproducido(t *testing.T) {
+	r := prepararReproduccion(t)
+
+	w := solicitar(r, "GET", "/search?originLocationCode=SCL&destinationLocationCode=MAD&departureDate=2030-03-10&adults=1&sort=price", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("estado = %d: %s", w.Code, w.Body)
+	}
+	var resultado ResultadoBusqueda
+	if err := json.Unmarshal(w.Body.Bytes(), &resultado); err != nil {
+		t.Fatal(err)
+	}
+	if len(resultado.Ofertas) != 2 || len(resultado.Advertencias) != 0 {
+		t.Fatalf("ofertas = %d, advertencias = %v", len(resultado.Ofertas), resultado.Advertencias)
+	}
+	if resultado.Ofertas[0].Id != "1" || resultado.Ofertas[0].Price.GrandTotal != "812.34" {
+		t.Errorf("primera oferta = %s a %s, se esperaba la 1 a 812.34", resultado.Ofertas[0].Id, resultado.Ofertas[0].Price.GrandTotal)
+	}
+	if resultado.Ofertas[0].Emisiones == nil {
+		t.Error("las ofertas no traen la estimación de emisiones")
+	}
+
+	if w := solicitar(r, "GET", "/search?originLocationCode=SCL&destinationLocationCode=MAD&departureDate=2030-03-10&adults=1&sort=duracion", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("orden no válido: estado = %d, se esperaba 400", w.Code)
+	}
+}
+
+func TestObtenerPreciosReproducido(t *testing.T) {
+	r := prepararReproduccion(t)
+
+	w := solicitar(r, "POST", "/pricing", "pricing.json")
+	if w.Code != http.StatusOK {
+		t.Fatalf("estado = %d: %s", w.Code, w.Body)
+	}
+	var precios PricingResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &precios); err != nil {
+		t.Fatal(err)
+	}
+	if len(precios.FlightOffers) != 1 || precios.FlightOffers[0].Price.GrandTotal != "812.34" {
+		t.Fatalf("ofertas cotizadas = %+v", precios.FlightOffers)
+	}
+	if len(precios.Included.Bags) != 1 {
+		t.Errorf("equipaje = %+v, se esperaba una opción", precios.Included.Bags)
+	}
+	if len(precios.Condiciones) != 1 || precios.Condiciones[0].Cambio.Monto != "150.00" {
+		t.Errorf("condiciones = %+v, se esperaba el cambio a 150.00", precios.Condiciones)
+	}
+}
+
+func TestHacerReservaReproducida(t *testing.T) {
+	r := prepararReproduccion(t)
+	mongo := nuevoMongoFalso(t)
+	var err error
+	clavesPII, err = cargarClavesPII("prueba:MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := solicitar(r, "POST", "/booking", "booking.json")
+	if w.Code != http.StatusOK {
+		t.Fatalf("estado = %d: %s", w.Code, w.Body)
+	}
+	var respuesta struct {
+		Id          string `json:"id"`
+		Advertencia string `json:"warning"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &respuesta); err != nil {
+		t.Fatal(err)
+	}
+	if respuesta.Id != ordenFixture || respuesta.Advertencia != "" {
+		t.Fatalf("respuesta = %+v", respuesta)
+	}
+
+	// La reserva queda guardada con los datos de los pasajeros cifrados
+	guardadas := mongo.documentos(coleccionReservas)
+	if len(guardadas) != 1 {
+		t.Fatalf("reservas guardadas = %d, se esperaba 1", len(guardadas))
+	}
+	var guardada ReservaGuardada
+	if err := bson.Unmarshal(guardadas[0], &guardada); err != nil {
+		t.Fatal(err)
+	}
+	if guardada.Data.Id != ordenFixture || len(guardada.Data.Travelers) != 1 {
+		t.Fatalf("reserva guardada = %+v", guardada.Data)
+	}
+	// La fixture trae los nombres ya redactados; igual deben quedar cifrados
+	if nombre := guardada.Data.Travelers[0].Name.FirstName; !strings.HasPrefix(nombre, prefijoCifrado) {
+		t.Errorf("el nombre del pasajero se guardó sin cifrar: %q", nombre)
+	}
+	if err := descifrarReserva(&guardada.Booking); err != nil || guardada.Data.Travelers[0].Name.FirstName != textoRedactado {
+		t.Errorf("descifrarReserva = %v, nombre %q", err, guardada.Data.Travelers[0].Name.FirstName)
+	}
+}
+
+func TestHacerReservaRechazadaReproducida(t *testing.T) {
+	r := prepararReproduccion(t)
+	mongo := nuevoMongoFalso(t)
+
+	w := solicitar(r, "POST", "/booking", "booking_rechazada.json")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("estado = %d, se esperaba el 400 del proveedor: %s", w.Code, w.Body)
+	}
+	if !strings.Contains(w.Body.String(), "SEGMENT SELL FAILURE") {
+		t.Errorf("la respuesta no trae el motivo del proveedor: %s", w.Body)
+	}
+	if guardadas := mongo.documentos(coleccionReservas); len(guardadas) != 0 {
+		t.Errorf("se guardaron %d reservas rechazadas", len(guardadas))
+	}
+}
+
+func TestHacerReservaIlegibleReproducida(t *testing.T) {
+	r := prepararReproduccion(t)
+	mongo := nuevoMongoFalso(t)
+
+	// El proveedor responde 201 con un cuerpo que no es una orden: la
+	// reserva existe, por lo que no se responde un error
+	w := solicitar(r, "POST", "/booking", "booking_ilegible.json")
+	if w.Code != http.StatusOK {
+		t.Fatalf("estado = %d: %s", w.Code, w.Body)
+	}
+	if !strings.Contains(w.Body.String(), `"warning"`) {
+		t.Errorf("la respuesta no trae la advertencia: %s", w.Body)
+	}
+
+	guardadas := mongo.documentos(coleccionReservas)
+	if len(guardadas) != 1 {
+		t.Fatalf("reservas guardadas = %d, se esperaba 1", len(guardadas))
+	}
+	var guardada ReservaGuardada
+	if err := bson.Unmarshal(guardadas[0], &guardada); err != nil {
+		t.Fatal(err)
+	}
+	if !guardada.RequiereRevision || guardada.Crudo == "" || len(guardada.Historial) != 1 || guardada.Historial[0].Tipo != cambioRespuestaIlegible {
+		t.Errorf("reserva guardada = %+v", guardada)
+	}
+}
+
+func TestBuscarIdReproducido(t *testing.T) {
+	r := prepararReproduccion(t)
+
+	w := solicitar(r, "GET", "/booking?id="+ordenFixture, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("estado = %d: %s", w.Code, w.Body)
+	}
+	var orden struct {
+		Id        string      `json:"id"`
+		Travelers []Travelers `json:"travelers"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &orden); err != nil {
+		t.Fatal(err)
+	}
+	if orden.Id != ordenFixture || len(orden.Travelers) != 1 {
+		t.Fatalf("orden = %+v", orden)
+	}
+	// Las fixtures se graban sin los datos personales de los pasajeros
+	if nombre := orden.Travelers[0].Name.FirstName; nombre != textoRedactado && os.Getenv("GRABAR_AMADEUS") == "" {
+		t.Errorf("nombre del pasajero en la fixture = %q, se esperaba %q", nombre, textoRedactado)
+	}
+}
+
+func TestGrabarFixtureSinTokenNiDatosPersonales(t *testing.T) {
+	proveedor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == rutaToken {
+			w.Write([]byte(`{"access_token":"tokenreal123","expires_in":1799}`))
+			return
+		}
+		w.Write([]byte(`{"data":{"id":"ABC","travelers":[{"id":"1","dateOfBirth":"1990-05-20","name":{"firstName":"ANA","lastName":"PEREZ"},"contact":{"emailAddress":"ana@pasajero.test","phones":[{"number":"912345678"}]}}]}}`))
+	}))
+	defer proveedor.Close()
+
+	directorio := t.TempDir()
+	cliente := &http.Client{Transport: &transporteFixtures{base: http.DefaultTransport, directorio: directorio, grabar: true}}
+	for _, ruta := range []string{rutaToken, "/v1/booking/flight-orders/ABC"} {
+		resp, err := cliente.Post(proveedor.URL+ruta, "application/x-www-form-urlencoded", strings.NewReader("client_id=cliente&client_secret=secreto"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	archivos, _ := filepath.Glob(filepath.Join(directorio, "*.json"))
+	if len(archivos) != 2 {
+		t.Fatalf("fixtures grabadas = %v", archivos)
+	}
+	for _, archivo := range archivos {
+		contenido, _ := os.ReadFile(archivo)
+		for _, dato := range []string{"tokenreal123", "secreto", "ANA", "PEREZ", "1990-05-20", "ana@pasajero.test", "912345678"} {
+			if strings.Contains(string(contenido), dato) {
+				t.Errorf("%s contiene %q:\n%s", filepath.Base(archivo), dato, contenido)
+			}
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Tipos de cambio detectados al sincronizar una reserva con el proveedor
+const (
+	cambioHorario   = "CAMBIO_HORARIO"
+	cambioCancelada = "CANCELADA"
+	cambioEmitida   = "EMITIDA"
+)
+
+// Cambio detectado en una reserva. Se agrega al historial del documento
+type CambioReserva struct {
+	Fecha   time.Time `bson:"fecha" json:"fecha"`
+	Tipo    string    `bson:"tipo" json:"tipo"`
+	Detalle string    `bson:"detalle" json:"detalle"`
+}
+
+var errOrdenNoEncontrada = errors.New("la orden no existe en el proveedor")
+
+// Revisa las reservas activas al iniciar y luego cada
+// config.IntervaloSincronizacion, hasta que se cancele ctx
+func iniciarSincronizacion(ctx context.Context) {
+	if config.IntervaloSincronizacion <= 0 {
+		return
+	}
+
+	enSegundoPlano(func() {
+		ticker := time.NewTicker(config.IntervaloSincronizacion)
+		defer ticker.Stop()
+		for {
+			if n, err := paraCadaAgencia(ctx, func(ctx context.Context) (int, error) { return sincronizarReservas(ctx, time.Now()) }); err != nil {
+				slog.ErrorContext(ctx, "Error al sincronizar las reservas", "error", err)
+			} else if n > 0 {
+				slog.InfoContext(ctx, "Sincronización de reservas", "cambios", n)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+// Consulta en el proveedor cada reserva activa (no cancelada y con algun
+// vuelo por salir) y guarda los cambios encontrados. Devuelve la cantidad
+// de reservas con cambios
+func sincronizarReservas(ctx context.Context, ahora time.Time) (int, error) {
+	clientDB, err := conectarMongo(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer clientDB.Disconnect(context.TODO())
+
+	filtro := bson.M{
+		"cancelada":   bson.M{"$ne": true},
+		"anonimizada": bson.M{"$ne": true},
+		"data.id":     bson.M{"$nin": []interface{}{"", nil}},
+		"data.flightoffers.itineraries.segments.departure.at": bson.M{"$gte": ahora.Format(formatoAmadeus)},
+	}
+	collection := clientDB.Database(baseDeDatos).Collection(coleccionReservasDe(ctx))
+	cursor, err := collection.Find(ctx, filtro, options.Find().SetProjection(bson.M{"data.travelers": 0}))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	conCambios := 0
+	for cursor.Next(ctx) {
+		var documento struct {
+			Id      interface{} `bson:"_id"`
+			Booking `bson:",inline"`
+		}
+		if err := cursor.Decode(&documento); err != nil {
+			return conCambios, err
+		}
+
+		actual, err := obtenerOrden(ctx, documento.Data.Id)
+		cambios := []CambioReserva{}
+		set := bson.M{"ultimaSincronizacion": ahora.UTC()}
+		switch {
+		case errors.Is(err, errOrdenNoEncontrada):
+			cambios = append(cambios, CambioReserva{Fecha: ahora.UTC(), Tipo: cambioCancelada, Detalle: "La orden ya no existe en el proveedor"})
+			set["cancelada"] = true
+			set["fechaCancelacion"] = ahora.UTC()
+		case err == nil && len(actual.Data.FlightOffers) == 0:
+			slog.WarnContext(ctx, "La orden llegó sin vuelos, se omite", "reserva", documento.Data.Id)
+			continue
+		case err != nil:
+			// Un error del proveedor no cambia la reserva; se reintenta en la
+			// siguiente vuelta
+			slog.ErrorContext(ctx, "Error al consultar la reserva", "reserva", documento.Data.Id, "error", err)
+			continue
+		default:
+			cambios = compararReservas(documento.Booking, actual, ahora.UTC())
+			set["data.flightoffers"] = actual.Data.FlightOffers
+			set["data.tickets"] = actual.Data.Tickets
+		}
+
+		actualizacion := bson.M{"$set": set}
+		if len(cambios) > 0 {
+			set["requiereRevision"] = true
+			actualizacion["$push"] = bson.M{"historial": bson.M{"$each": cambios}}
+		}
+		if _, err := collection.UpdateOne(ctx, bson.M{"_id": documento.Id}, actualizacion); err != nil {
+			return conCambios, err
+		}
+		for _, cambio := range cambios {
+			auditarReserva(ctx, cambio.Tipo, documento.Data.Id, 0)
+		}
+		if len(cambios) > 0 {
+			conCambios++
+		}
+	}
+	return conCambios, cursor.Err()
+}
+
+// Consulta una orden en el proveedor
+func obtenerOrden(ctx context.Context, id string) (Booking, error) {
+	token, err := obtenerToken(ctx)
+	if err != nil {
+		return Booking{}, fmt.Errorf("error al obtener el token: %w", err)
+	}
+
+	// Los ids guardados pueden venir ya codificados (por ejemplo con %3D)
+	ruta := id
+	if decodificado, err := url.PathUnescape(id); err == nil {
+		ruta = url.PathEscape(decodificado)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", config.URLProveedor+"/v1/booking/flight-orders/"+ruta, nil)
+	if err != nil {
+		return Booking{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := clienteAmadeus.Do(req)
+	if err != nil {
+		return Booking{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Booking{}, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return Booking{}, errOrdenNoEncontrada
+	}
+	if resp.StatusCode >= 400 {
+		return Booking{}, fmt.Errorf("el proveedor respondió %d: %s", resp.StatusCode, body)
+	}
+
+	var orden Booking
+	if err := json.Unmarshal(body, &orden); err != nil {
+		return Booking{}, err
+	}
+	return orden, nil
+}
+
+// Compara la reserva guardada con la orden actual del proveedor y describe
+// los cambios de horario, segmentos eliminados o agregados y boletos nuevos
+func compararReservas(guardada, actual Booking, ahora time.Time) []CambioReserva {
+	var cambios []CambioReserva
+	agregar := func(tipo, detalle string) {
+		cambios = append(cambios, CambioReserva{Fecha: ahora, Tipo: tipo, Detalle: detalle})
+	}
+
+	anteriores := segmentosPorId(guardada)
+	nuevos := segmentosPorId(actual)
+	for _, id := range idsSegmentos(guardada) {
+		antes := anteriores[id]
+		despues, ok := nuevos[id]
+		vuelo := antes.CarrierCode + antes.Number
+		switch {
+		case !ok:
+			agregar(cambioHorario, fmt.Sprintf("Segmento %s (%s %s-%s) eliminado", id, vuelo, antes.Departure.IataCode, antes.Arrival.IataCode))
+		case antes.CarrierCode != despues.CarrierCode || antes.Number != despues.Number:
+			agregar(cambioHorario, fmt.Sprintf("Segmento %s: vuelo %s cambiado a %s%s", id, vuelo, despues.CarrierCode, despues.Number))
+		case antes.Departure.At != despues.Departure.At || antes.Arrival.At != despues.Arrival.At:
+			agregar(cambioHorario, fmt.Sprintf("Segmento %s (%s): salida %s -> %s, llegada %s -> %s", id, vuelo, antes.Departure.At, despues.Departure.At, antes.Arrival.At, despues.Arrival.At))
+		}
+	}
+	for _, id := range idsSegmentos(actual) {
+		if _, ok := anteriores[id]; !ok {
+			s := nuevos[id]
+			agregar(cambioHorario, fmt.Sprintf("Segmento %s (%s%s %s-%s) agregado", id, s.CarrierCode, s.Number, s.Departure.IataCode, s.Arrival.IataCode))
+		}
+	}
+
+	emitidos := make(map[string]bool)
+	for _, boleto := range guardada.Data.Tickets {
+		emitidos[boleto.DocumentNumber] = true
+	}
+	for _, boleto := range actual.Data.Tickets {
+		if !emitidos[boleto.DocumentNumber] {
+			agregar(cambioEmitida, fmt.Sprintf("Boleto %s emitido para el pasajero %s (%s)", boleto.DocumentNumber, boleto.TravelerId, boleto.DocumentStatus))
+		}
+	}
+	return cambios
+}
+
+func segmentosPorId(reserva Booking) map[string]Segment {
+	segmentos := make(map[string]Segment)
+	for _, oferta := range reserva.Data.FlightOffers {
+		for _, itinerario := range oferta.Itineraries {
+			for _, segmento := range itinerario.Segments {
+				segmentos[segmento.Id] = segmento
+			}
+		}
+	}
+	return segmentos
+}
+
+// Ids de los segmentos en el orden del itinerario
+func idsSegmentos(reserva Booking) []string {
+	var ids []string
+	for _, oferta := range reserva.Data.FlightOffers {
+		for _, itinerario := range oferta.Itineraries {
+			for _, segmento := range itinerario.Segments {
+				ids = append(ids, segmento.Id)
+			}
+		}
+	}
+	return ids
+}
+
+// Reserva marcada para revision por un agente
+type ReservaMarcada struct {
+	Id                   string          `bson:"-" json:"id"`
+	UltimaSincronizacion time.Time       `bson:"ultimaSincronizacion" json:"ultimaSincronizacion"`
+	Cancelada            bool            `bson:"cancelada" json:"cancelada"`
+	Historial            []CambioReserva `bson:"historial" json:"historial"`
+	Data                 struct {
+		Id string `bson:"id"`
+	} `bson:"data" json:"-"`
+}
+
+// Lista las reservas con cambios pendientes de revision
+func consultarReservasMarcadas(c *gin.Context) {
+	limite := int64(100)
+	if valor := c.Query("limite"); valor != "" {
+		n, err := strconv.ParseInt(valor, 10, 64)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limite no válido"})
+			return
+		}
+		limite = n
+	}
+
+	ctx := c.Request.Context()
+	clientDB, err := conectarMongo(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al conectar con la base de datos"})
+		return
+	}
+	defer clientDB.Disconnect(context.TODO())
+
+	collection := clientDB.Database(baseDeDatos).Collection(coleccionReservasDe(ctx))
+	opciones := options.Find().
+		SetProjection(bson.M{"data.id": 1, "ultimaSincronizacion": 1, "cancelada": 1, "historial": 1}).
+		SetSort(bson.M{"ultimaSincronizacion": -1}).
+		SetLimit(limite)
+	cursor, err := collection.Find(ctx, bson.M{"requiereRevision": true}, opciones)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	marcadas := []ReservaMarcada{}
+	if err := cursor.All(ctx, &marcadas); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range marcadas {
+		marcadas[i].Id = marcadas[i].Data.Id
+	}
+	c.JSON(http.StatusOK, marcadas)
+}
+
+// Quita la marca de revision de una reserva una vez que un agente la revisó
+func marcarReservaRevisada(c *gin.Context) {
+	id := c.Param("id")
+
+	ctx := c.Request.Context()
+	clientDB, err := conectarMongo(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al conectar con la base de datos"})
+		return
+	}
+	defer clientDB.Disconnect(context.TODO())
+
+	collection := clientDB.Database(baseDeDatos).Collection(coleccionReservasDe(ctx))
+	filtro := bson.M{"data.id": bson.M{"$in": []string{id, url.QueryEscape(id)}}}
+	resultado, err := collection.UpdateOne(ctx, filtro, bson.M{"$set": bson.M{"requiereRevision": false}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if resultado.MatchedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": errReservaNoEncontrada.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "requiereRevision": false})
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// Penalidad de una condicion de la tarifa. Permitido queda vacio y
+// Desconocida en true cuando ni las reglas ni el texto de la tarifa lo
+// informan; Nota resume el texto de la regla si existe
+type Penalidad struct {
+	Permitido   *bool  `json:"allowed,omitempty"`
+	Monto       string `json:"amount,omitempty"`
+	Moneda      string `json:"currency,omitempty"`
+	Nota        string `json:"note,omitempty"`
+	Desconocida bool   `json:"unknown,omitempty"`
+}
+
+// Condiciones de cambio, reembolso y no presentacion de un segmento
+type CondicionTarifa struct {
+	OfertaId   string    `json:"offerId"`
+	SegmentoId string    `json:"segmentId"`
+	Ruta       string    `json:"route"`
+	BaseTarifa string    `json:"fareBasis,omitempty"`
+	Marca      string    `json:"brandedFare,omitempty"`
+	Cambio     Penalidad `json:"change"`
+	Reembolso  Penalidad `json:"refund"`
+	NoShow     Penalidad `json:"noShow"`
+}
+
+// Partes de la respuesta del pricing con include=detailed-fare-rules que se
+// usan para las condiciones
+type reglasPricing struct {
+	Data struct {
+		FlightOffers []struct {
+			Id        string `json:"id"`
+			FareRules struct {
+				Currency string `json:"currency"`
+				Rules    []struct {
+					Category         string `json:"category"`
+					MaxPenaltyAmount string `json:"maxPenaltyAmount"`
+					NotApplicable    bool   `json:"notApplicable"`
+				} `json:"rules"`
+			} `json:"fareRules"`
+		} `json:"flightOffers"`
+	} `json:"data"`
+	Included struct {
+		DetailedFareRules map[string]struct {
+			SegmentId string `json:"segmentId"`
+			FareBasis string `json:"fareBasis"`
+			Name      string `json:"name"`
+			FareNotes struct {
+				Descriptions []struct {
+					DescriptionType string `json:"descriptionType"`
+					Text            string `json:"text"`
+				} `json:"descriptions"`
+			} `json:"fareNotes"`
+		} `json:"detailed-fare-rules"`
+	} `json:"included"`
+}
+
+var (
+	lineaNoShow    = regexp.MustCompile(`(?i)[^.\n]*no[- ]?show[^.\n]*`)
+	lineaCambio    = regexp.MustCompile(`(?i)[^.\n]*(changes?|exchange)[^.\n]*`)
+	lineaReembolso = regexp.MustCompile(`(?i)[^.\n]*(refund|cancel)[^.\n]*`)
+
+	// Dentro de una nota: si la penalidad no se permite, si no tiene costo
+	// o el monto que se cobra
+	notaNoPermitida = regexp.MustCompile(`(?i)not (permitted|allowed)|non[- ]?refundable|no refund|forfeit`)
+	notaSinCosto    = regexp.MustCompile(`(?i)free of charge|without charge|no charge|no penalty`)
+	notaMonto       = regexp.MustCompile(`\b([A-Z]{3})\s*([0-9]+(?:\.[0-9]+)?)\b`)
+)
+
+// Normaliza las reglas de tarifa del pricing en una condicion por segmento.
+// Los montos vienen de fareRules (por oferta) y las notas del texto de
+// penalidades de cada segmento
+func normalizarCondiciones(cuerpo []byte, ofertas []FlightOffer) []CondicionTarifa {
+	var reglas reglasPricing
+	if err := json.Unmarshal(cuerpo, &reglas); err != nil {
+		return nil
+	}
+
+	// Texto de penalidades por segmento
+	penalidades := make(map[string]string)
+	tarifas := make(map[string]string)
+	for clave, regla := range reglas.Included.DetailedFareRules {
+		segmento := regla.SegmentId
+		if segmento == "" {
+			segmento = clave
+		}
+		tarifas[segmento] = regla.Name
+		for _, descripcion := range regla.FareNotes.Descriptions {
+			if strings.EqualFold(descripcion.DescriptionType, "PENALTIES") {
+				penalidades[segmento] = descripcion.Text
+			}
+		}
+	}
+
+	condiciones := []CondicionTarifa{}
+	for i, oferta := range ofertas {
+		var cambio, reembolso, noShow Penalidad
+		if i < len(reglas.Data.FlightOffers) {
+			reglasOferta := reglas.Data.FlightOffers[i].FareRules
+			for _, regla := range reglasOferta.Rules {
+				penalidad := Penalidad{Moneda: reglasOferta.Currency}
+				permitido := !regla.NotApplicable
+				penalidad.Permitido = &permitido
+				if permitido {
+					penalidad.Monto = regla.MaxPenaltyAmount
+				}
+				switch regla.Category {
+				case "EXCHANGE":
+					cambio = penalidad
+				case "REFUND":
+					reembolso = penalidad
+				case "NO_SHOW", "NOSHOW":
+					noShow = penalidad
+				}
+			}
+		}
+
+		// Los segmentos se toman del primer pasajero; las condiciones de la
+		// tarifa son las mismas para todos
+		if len(oferta.TravelerPricings) == 0 {
+			continue
+		}
+		for _, detalle := range oferta.TravelerPricings[0].FareDetailsBySegment {
+			condicion := CondicionTarifa{
+				OfertaId:   oferta.Id,
+				SegmentoId: detalle.SegmentId,
+				Ruta:       rutaSegmento(oferta, detalle.SegmentId),
+				BaseTarifa: detalle.FareBasis,
+				Marca:      detalle.BrandedFare,
+				Cambio:     cambio,
+				Reembolso:  reembolso,
+				NoShow:     noShow,
+			}
+			if condicion.Marca == "" {
+				condicion.Marca = tarifas[detalle.SegmentId]
+			}
+			if texto := penalidades[detalle.SegmentId]; texto != "" {
+				condicion.Cambio.Nota = primeraCoincidencia(lineaCambio, texto)
+				condicion.Reembolso.Nota = primeraCoincidencia(lineaReembolso, texto)
+				condicion.NoShow.Nota = primeraCoincidencia(lineaNoShow, texto)
+			}
+			completarConNota(&condicion.Cambio)
+			completarConNota(&condicion.Reembolso)
+			completarConNota(&condicion.NoShow)
+			condiciones = append(condiciones, condicion)
+		}
+	}
+	return condiciones
+}
+
+// Cuando fareRules no informa la penalidad la deduce de su nota (no
+// permitida, sin costo o un monto con su moneda). Si la nota tampoco lo
+// dice, la marca como desconocida
+func completarConNota(penalidad *Penalidad) {
+	if penalidad.Permitido != nil {
+		return
+	}
+	permitido := true
+	switch {
+	case notaNoPermitida.MatchString(penalidad.Nota):
+		permitido = false
+	case notaSinCosto.MatchString(penalidad.Nota):
+		penalidad.Monto = "0"
+	default:
+		monto := notaMonto.FindStringSubmatch(penalidad.Nota)
+		if monto == nil {
+			penalidad.Desconocida = true
+			return
+		}
+		penalidad.Moneda, penalidad.Monto = monto[1], monto[2]
+	}
+	penalidad.Permitido = &permitido
+}
+
+func primeraCoincidencia(patron *regexp.Regexp, texto string) string {
+	return strings.Join(strings.Fields(patron.FindString(texto)), " ")
+}
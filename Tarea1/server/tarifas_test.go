@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestNormalizarCondicionesNoShow(t *testing.T) {
+	oferta := ofertaPrueba("1", "IB6830", "812.34")
+	oferta.Itineraries[0].Segments[0].Id = "1"
+	oferta.TravelerPricings = make([]TravelerPricing, 1)
+	oferta.TravelerPricings[0].FareDetailsBySegment = make([]FareDetailsBySegment, 1)
+	oferta.TravelerPricings[0].FareDetailsBySegment[0].SegmentId = "1"
+
+	casos := []struct {
+		nombre, texto string
+		permitido     *bool
+		monto, moneda string
+	}{
+		{"con monto", "CHANGES PERMITTED FOR EUR 50. NO SHOW PENALTY EUR 120.", puntero(true), "120", "EUR"},
+		{"no permitido", "NO-SHOW: TICKET IS NON-REFUNDABLE.", puntero(false), "", ""},
+		{"sin costo", "NO SHOW FREE OF CHARGE.", puntero(true), "0", ""},
+		{"desconocido", "CHANGES PERMITTED.", nil, "", ""},
+	}
+	for _, caso := range casos {
+		cuerpo := []byte(`{"data":{"flightOffers":[{"id":"1","fareRules":{"currency":"EUR","rules":[{"category":"EXCHANGE","maxPenaltyAmount":"50"}]}}]},
+			"included":{"detailed-fare-rules":{"1":{"segmentId":"1","fareNotes":{"descriptions":[{"descriptionType":"PENALTIES","text":"` + caso.texto + `"}]}}}}}`)
+		condiciones := normalizarCondiciones(cuerpo, []FlightOffer{oferta})
+		if len(condiciones) != 1 {
+			t.Fatalf("%s: condiciones = %d", caso.nombre, len(condiciones))
+		}
+		noShow := condiciones[0].NoShow
+		switch {
+		case caso.permitido == nil && (noShow.Permitido != nil || !noShow.Desconocida):
+			t.Errorf("%s: no show = %+v, se esperaba desconocido", caso.nombre, noShow)
+		case caso.permitido != nil && (noShow.Permitido == nil || *noShow.Permitido != *caso.permitido || noShow.Desconocida):
+			t.Errorf("%s: no show = %+v, se esperaba permitido %v", caso.nombre, noShow, *caso.permitido)
+		case noShow.Monto != caso.monto || noShow.Moneda != caso.moneda:
+			t.Errorf("%s: monto = %q %q, se esperaba %q %q", caso.nombre, noShow.Monto, noShow.Moneda, caso.monto, caso.moneda)
+		}
+		if cambio := condiciones[0].Cambio; cambio.Permitido == nil || cambio.Monto != "50" {
+			t.Errorf("%s: cambio = %+v", caso.nombre, cambio)
+		}
+	}
+}
+
+func puntero(valor bool) *bool { return &valor }
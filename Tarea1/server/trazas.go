@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+const nombreServicio = "gotravel-server"
+
+// Configura OpenTelemetry segun OTEL_TRACES_EXPORTER: "otlp" exporta por
+// HTTP al endpoint de OTEL_EXPORTER_OTLP_ENDPOINT, "stdout" escribe las
+// trazas en consola y cualquier otro valor las deshabilita. La propagacion
+// W3C trace context queda activa en todos los casos. Devuelve la funcion
+// que vacia y cierra el exportador
+func iniciarTrazas(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exportador sdktrace.SpanExporter
+	var err error
+	switch os.Getenv("OTEL_TRACES_EXPORTER") {
+	case "otlp":
+		exportador, err = otlptracehttp.New(ctx)
+	case "stdout", "console":
+		exportador, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return func(context.Context) error { return nil }, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo crear el exportador de trazas: %w", err)
+	}
+
+	proveedor := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exportador),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(nombreServicio),
+		)),
+	)
+	otel.SetTracerProvider(proveedor)
+	return proveedor.Shutdown, nil
+}
+
+// Combina varios monitores de MongoDB, ya que el driver admite solo uno
+func combinarMonitores(monitores ...*event.CommandMonitor) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			for _, m := range monitores {
+				if m.Started != nil {
+					m.Started(ctx, e)
+				}
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			for _, m := range monitores {
+				if m.Succeeded != nil {
+					m.Succeeded(ctx, e)
+				}
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			for _, m := range monitores {
+				if m.Failed != nil {
+					m.Failed(ctx, e)
+				}
+			}
+		},
+	}
+}